@@ -3,6 +3,7 @@ package codegen
 import (
 	"bytes"
 	"fmt"
+	"go/types"
 	"math"
 	"strings"
 
@@ -22,21 +23,6 @@ var Debug_checknil int
 // Smallest possible faulting page at address zero.
 const minZeroPage = 4096
 
-func Warn(fmt_ string, args ...interface{}) {
-	if len(args) > 0 {
-		fmt.Printf("Warning: "+fmt_+"\n", args)
-	} else {
-		fmt.Printf("Warning: " + fmt_ + "\n")
-	}
-}
-
-func Warnl(line int, fmt_ string, args ...interface{}) {
-	if len(args) > 0 {
-		fmt.Printf("Warning (line %v): "+fmt_+" \n", line, args)
-	} else {
-		fmt.Printf("Warning (line %v): "+fmt_+" \n", line)
-	}
-}
 
 // regnum returns the register (in cmd/internal/obj numbering) to
 // which v has been allocated.  Panics if v is not assigned to a
@@ -51,11 +37,11 @@ func regnum(v *ssa.Value) int16 {
 	return ssaRegToReg[reg.(*ssa.Register).Num()]
 }
 
-// autoVar returns a *Node and int64 representing the auto variable and offset within it
-// where v should be spilled.
-func autoVar(v *ssa.Value) (*Node, int64) {
+// autoVar returns the variable and the offset within it where v should
+// be spilled, a param or a local in the frame.
+func autoVar(v *ssa.Value) (ssaVar, int64) {
 	loc := v.Block.Func.RegAlloc[v.ID].(ssa.LocalSlot)
-	return loc.N.(*Node), loc.Off
+	return loc.N.(ssaVar), loc.Off
 }
 
 type LSym struct {
@@ -229,6 +215,7 @@ const (
 	AUSEFIELD
 	AVARDEF
 	AVARKILL
+	ALABEL // the label of a block, "b3:"
 	A_ARCHSPECIFIC
 )
 
@@ -293,6 +280,7 @@ var Anames = []string{
 	"USEFIELD",
 	"VARDEF",
 	"VARKILL",
+	"LABEL",
 }
 
 func Bool2int(b bool) int {
@@ -335,6 +323,8 @@ func Dconv(p *Prog, a *Addr) string {
 			str = fmt.Sprintf("%s(SB)", a.Sym.Name)
 		} else if p != nil && p.Pcond != nil {
 			str = fmt.Sprint(p.Pcond.Pc)
+		} else if q, ok := a.Val.(*Prog); ok && q.As == ALABEL {
+			str = q.From.Sym.Name
 		} else if a.Val != nil {
 			str = fmt.Sprint(a.Val.(*Prog).Pc)
 		} else {
@@ -348,7 +338,6 @@ func Dconv(p *Prog, a *Addr) string {
 		str = Mconv(a)
 		if a.Index != REG_NONE {
 			str += fmt.Sprintf("(%v*%d)", Rconv(int(a.Index)), int(a.Scale))
-			panic("unexpected")
 		}
 
 	case TYPE_CONST:
@@ -440,8 +429,9 @@ func Mconv(a *Addr) string {
 		}
 
 	case NAME_PARAM:
+		// go vet checks the name and offset of each arg, "x+0(FP)"
 		if a.Sym != nil {
-			str = fmt.Sprintf("%s%s(FP)", a.Sym.Name, offConv(a.Offset))
+			str = fmt.Sprintf("%s%+d(FP)", a.Sym.Name, a.Offset)
 		} else {
 			str = fmt.Sprintf("%s(FP)", offConv(a.Offset))
 		}
@@ -502,6 +492,9 @@ func (p *Prog) String() string {
 
 func (p *Prog) Sprint(verbose bool) string {
 	var buf bytes.Buffer
+	if p.As == ALABEL {
+		return p.From.Sym.Name + ":"
+	}
 	if verbose {
 		fmt.Fprintf(&buf, "%.5d (%v)\t%v", p.Pc, p.Line(), Aconv(int(p.As)))
 	} else {
@@ -554,6 +547,12 @@ type genState struct {
 
 	// deferTarget remembers the (last) deferreturn call site.
 	deferTarget *Prog
+
+	// progs are the instructions emitted for the current value.
+	progs []*Prog
+
+	// labels counts the labels within values.
+	labels int
 }
 
 func Preamble() string {
@@ -579,16 +578,22 @@ func GenGoProto(f *ssa.Func) (proto string, ok bool) {
 	if f == nil {
 		return "", false
 	}
-
-	proto = fmt.Sprintf("func %v()\n", f.Name)
-	return proto, true
+	t, ok := f.Type.(*Type)
+	if !ok {
+		return "", false
+	}
+	sig, ok := t.Type.(*types.Signature)
+	if !ok {
+		return "", false
+	}
+	return GoProto(f.Name, sig), true
 }
 
 func GenAsm(f *ssa.Func) (asm string, ok bool) {
 	if f == nil {
 		return "", false
 	}
-	asm = FuncProto(f.Name, 0, 0)
+	asm = FuncProto(f.Name, int(frameSize(f)), int(argsSize(f)))
 	progs, success := GenProg(f)
 	if !success {
 		return "", false
@@ -600,9 +605,31 @@ func GenAsm(f *ssa.Func) (asm string, ok bool) {
 	return asm, true
 }
 
-func GenProg(f *ssa.Func) (fnProg []*Prog, ok bool) {
+// frameSize returns the size of the frame of f, its spill slots and
+// the locals with their address taken.
+func frameSize(f *ssa.Func) int64 {
+	e, ok := f.Config.Frontend().(*ssaExport)
+	if !ok {
+		return 0
+	}
+	e.AllocFrame(f)
+	return e.frameSize
+}
 
-	Pc := new(Prog)
+// argsSize returns the size of the params and results of f.
+func argsSize(f *ssa.Func) int64 {
+	t, ok := f.Type.(*Type)
+	if !ok {
+		return 0
+	}
+	sig, ok := t.Type.(*types.Signature)
+	if !ok {
+		return 0
+	}
+	return ArgsSize(sig)
+}
+
+func GenProg(f *ssa.Func) (fnProg []*Prog, ok bool) {
 
 	var s genState
 
@@ -622,12 +649,18 @@ func GenProg(f *ssa.Func) (fnProg []*Prog, ok bool) {
 		valueProgs = make(map[*Prog]*ssa.Value, f.NumValues())
 		blockProgs = make(map[*Prog]*ssa.Block, f.NumBlocks())
 		f.Logf("genssa %s\n", f.Name)
-		blockProgs[Pc] = f.Blocks[0]
 	}
 	var funcProgs []*Prog
 	// Emit basic blocks
 	for i, b := range f.Blocks {
-		s.bstart[b.ID] = Pc
+		s.bstart[b.ID] = labelProg(fmt.Sprintf("b%d", b.ID))
+		if i > 0 {
+			// the entry block is first, nothing jumps to it
+			funcProgs = append(funcProgs, s.bstart[b.ID])
+			if logProgs {
+				blockProgs[s.bstart[b.ID]] = b
+			}
+		}
 		// Emit values in block
 		for _, v := range b.Values {
 			//x := Pc
@@ -676,8 +709,7 @@ func GenProg(f *ssa.Func) (fnProg []*Prog, ok bool) {
 			} else {
 				s = "   " // most value and branch strings are 2-3 characters long
 			}
-			//f.Logf("%s\t%s\n", s, p)
-			fmt.Println("ASM: ", s, "\t", p)
+			f.Logf("%s\t%s\n", s, p)
 		}
 	}
 
@@ -713,6 +745,16 @@ func GenProg(f *ssa.Func) (fnProg []*Prog, ok bool) {
 	return p
 }*/
 
+// labelProg returns the label, name, "b3:" for block b3, the branches
+// to it jump to the instruction after it.
+func labelProg(name string) *Prog {
+	p := NewProg()
+	p.As = ALABEL
+	p.From.Type = TYPE_BRANCH
+	p.From.Sym = &LSym{Name: name}
+	return p
+}
+
 func NewProg() *Prog {
 	p := new(Prog) // should be the only call to this; all others should use ctxt.NewProg
 	//p.Ctxt = ctxt
@@ -730,10 +772,6 @@ func CreateProg(as int) *Prog {
 		p = NewProg()
 	}
 
-	if lineno == 0 {
-		Warn("prog: line num (0) not set")
-	}
-
 	p.As = int16(as)
 	p.Lineno = lineno
 	return p
@@ -774,12 +812,29 @@ const (
 	TYPE_REGLIST
 )
 
+// prog emits an instruction of the value or block being generated
+// and returns it so it may be filled in.
+func (s *genState) prog(as int) *Prog {
+	p := CreateProg(as)
+	s.progs = append(s.progs, p)
+	return p
+}
+
+// label emits a label for the branches between the instructions of a
+// value, "l1:", the labels of blocks are b and the block ID.
+func (s *genState) label() *Prog {
+	s.labels++
+	p := labelProg(fmt.Sprintf("l%d", s.labels))
+	s.progs = append(s.progs, p)
+	return p
+}
+
 // opregreg emits instructions for
 //     dest := dest(To) op src(From)
 // and also returns the created obj.Prog so it
 // may be further adjusted (offset, scale, etc).
-func opregreg(op int, dest, src int16) *Prog {
-	p := CreateProg(op)
+func (s *genState) opregreg(op int, dest, src int16) *Prog {
+	p := s.prog(op)
 	p.From.Type = TYPE_REG
 	p.To.Type = TYPE_REG
 	p.To.Reg = dest
@@ -788,28 +843,26 @@ func opregreg(op int, dest, src int16) *Prog {
 }
 
 func (s *genState) genValue(v *ssa.Value) []*Prog {
-	var progs []*Prog
+	s.progs = nil
 	var p *Prog
 	switch v.Op {
 	case ssa.OpAMD64ADDQ:
 		// TODO: use addq instead of leaq if target is in the right register.
-		p := CreateProg(x86.ALEAQ)
+		p := s.prog(x86.ALEAQ)
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		p.From.Scale = 1
 		p.From.Index = regnum(v.Args[1])
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64ADDL:
-		p = CreateProg(x86.ALEAL)
+		p = s.prog(x86.ALEAL)
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		p.From.Scale = 1
 		p.From.Index = regnum(v.Args[1])
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	// 2-address opcode arithmetic, symmetric
 	case ssa.OpAMD64ADDSS, ssa.OpAMD64ADDSD,
 		ssa.OpAMD64ANDQ, ssa.OpAMD64ANDL,
@@ -821,10 +874,10 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		x := regnum(v.Args[0])
 		y := regnum(v.Args[1])
 		if x != r && y != r {
-			opregreg(regMoveByTypeAMD64(v.Type), r, x)
+			s.opregreg(regMoveByTypeAMD64(v.Type), r, x)
 			x = r
 		}
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.To.Type = TYPE_REG
 		p.To.Reg = r
@@ -833,7 +886,6 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		} else {
 			p.From.Reg = x
 		}
-		progs = append(progs, p)
 	// 2-address opcode arithmetic, not symmetric
 	case ssa.OpAMD64SUBQ, ssa.OpAMD64SUBL:
 		r := regnum(v)
@@ -846,16 +898,15 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 			neg = true
 		}
 		if x != r {
-			opregreg(regMoveByTypeAMD64(v.Type), r, x)
+			s.opregreg(regMoveByTypeAMD64(v.Type), r, x)
 		}
-		opregreg(int(v.Op.Asm()), r, y)
+		s.opregreg(int(v.Op.Asm()), r, y)
 
 		if neg {
-			p = CreateProg(x86.ANEGQ) // TODO: use correct size?  This is mostly a hack until regalloc does 2-address correctly
+			p = s.prog(x86.ANEGQ) // TODO: use correct size?  This is mostly a hack until regalloc does 2-address correctly
 			p.To.Type = TYPE_REG
 			p.To.Reg = r
 		}
-		progs = append(progs, p)
 	case ssa.OpAMD64SUBSS, ssa.OpAMD64SUBSD, ssa.OpAMD64DIVSS, ssa.OpAMD64DIVSD:
 		r := regnum(v)
 		x := regnum(v.Args[0])
@@ -867,44 +918,47 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 			// register move y to x15
 			// register move x to y
 			// rename y with x15
-			opregreg(regMoveByTypeAMD64(v.Type), x15, y)
-			opregreg(regMoveByTypeAMD64(v.Type), r, x)
+			s.opregreg(regMoveByTypeAMD64(v.Type), x15, y)
+			s.opregreg(regMoveByTypeAMD64(v.Type), r, x)
 			y = x15
 		} else if x != r {
-			opregreg(regMoveByTypeAMD64(v.Type), r, x)
+			s.opregreg(regMoveByTypeAMD64(v.Type), r, x)
 		}
-		opregreg(int(v.Op.Asm()), r, y)
+		s.opregreg(int(v.Op.Asm()), r, y)
 
 	case ssa.OpAMD64DIVQ, ssa.OpAMD64DIVL, ssa.OpAMD64DIVW,
-		ssa.OpAMD64DIVQU, ssa.OpAMD64DIVLU, ssa.OpAMD64DIVWU:
+		ssa.OpAMD64DIVQU, ssa.OpAMD64DIVLU, ssa.OpAMD64DIVWU,
+		ssa.OpAMD64MODQ, ssa.OpAMD64MODL, ssa.OpAMD64MODW,
+		ssa.OpAMD64MODQU, ssa.OpAMD64MODLU, ssa.OpAMD64MODWU:
 
 		// Arg[0] is already in AX as it's the only register we allow
-		// and AX is the only output
+		// and AX is the only output of DIV, DX of MOD
 		x := regnum(v.Args[1])
 
 		// CPU faults upon signed overflow, which occurs when most
 		// negative int is divided by -1.
 		var j *Prog
 		if v.Op == ssa.OpAMD64DIVQ || v.Op == ssa.OpAMD64DIVL ||
-			v.Op == ssa.OpAMD64DIVW {
+			v.Op == ssa.OpAMD64DIVW || v.Op == ssa.OpAMD64MODQ ||
+			v.Op == ssa.OpAMD64MODL || v.Op == ssa.OpAMD64MODW {
 
 			var c *Prog
 			switch v.Op {
-			case ssa.OpAMD64DIVQ:
-				c = CreateProg(x86.ACMPQ)
-				j = CreateProg(x86.AJEQ)
+			case ssa.OpAMD64DIVQ, ssa.OpAMD64MODQ:
+				c = s.prog(x86.ACMPQ)
+				j = s.prog(x86.AJEQ)
 				// go ahead and sign extend to save doing it later
-				CreateProg(x86.ACQO)
+				s.prog(x86.ACQO)
 
-			case ssa.OpAMD64DIVL:
-				c = CreateProg(x86.ACMPL)
-				j = CreateProg(x86.AJEQ)
-				CreateProg(x86.ACDQ)
+			case ssa.OpAMD64DIVL, ssa.OpAMD64MODL:
+				c = s.prog(x86.ACMPL)
+				j = s.prog(x86.AJEQ)
+				s.prog(x86.ACDQ)
 
-			case ssa.OpAMD64DIVW:
-				c = CreateProg(x86.ACMPW)
-				j = CreateProg(x86.AJEQ)
-				CreateProg(x86.ACWD)
+			case ssa.OpAMD64DIVW, ssa.OpAMD64MODW:
+				c = s.prog(x86.ACMPW)
+				j = s.prog(x86.AJEQ)
+				s.prog(x86.ACWD)
 			}
 			c.From.Type = TYPE_REG
 			c.From.Reg = x
@@ -917,46 +971,43 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 
 		// for unsigned ints, we sign extend by setting DX = 0
 		// signed ints were sign extended above
-		if v.Op == ssa.OpAMD64DIVQU ||
-			v.Op == ssa.OpAMD64DIVLU ||
-			v.Op == ssa.OpAMD64DIVWU {
-			c := CreateProg(x86.AXORQ)
+		if v.Op == ssa.OpAMD64DIVQU || v.Op == ssa.OpAMD64MODQU ||
+			v.Op == ssa.OpAMD64DIVLU || v.Op == ssa.OpAMD64MODLU ||
+			v.Op == ssa.OpAMD64DIVWU || v.Op == ssa.OpAMD64MODWU {
+			c := s.prog(x86.AXORQ)
 			c.From.Type = TYPE_REG
 			c.From.Reg = x86.REG_DX
 			c.To.Type = TYPE_REG
 			c.To.Reg = x86.REG_DX
 		}
 
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = x
 
 		// signed division, rest of the check for -1 case
 		if j != nil {
-			j2 := CreateProg(obj.AJMP)
+			j2 := s.prog(obj.AJMP)
 			j2.To.Type = TYPE_BRANCH
 
+			j.To.Val = s.label()
 			var n *Prog
 			if v.Op == ssa.OpAMD64DIVQ || v.Op == ssa.OpAMD64DIVL ||
 				v.Op == ssa.OpAMD64DIVW {
 				// n * -1 = -n
-				n = CreateProg(x86.ANEGQ)
+				n = s.prog(x86.ANEGQ)
 				n.To.Type = TYPE_REG
 				n.To.Reg = x86.REG_AX
 			} else {
 				// n % -1 == 0
-				n = CreateProg(x86.AXORQ)
+				n = s.prog(x86.AXORQ)
 				n.From.Type = TYPE_REG
 				n.From.Reg = x86.REG_DX
 				n.To.Type = TYPE_REG
 				n.To.Reg = x86.REG_DX
 			}
-
-			j.To.Val = n
-			panic("TODO")
-			//j2.To.Val = Pc
+			j2.To.Val = s.label()
 		}
-		progs = append(progs, p)
 	case ssa.OpAMD64HMULL, ssa.OpAMD64HMULW, ssa.OpAMD64HMULB,
 		ssa.OpAMD64HMULLU, ssa.OpAMD64HMULWU, ssa.OpAMD64HMULBU:
 		// the frontend rewrites constant division by 8/16/32 bit integers into
@@ -964,41 +1015,39 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 
 		// Arg[0] is already in AX as it's the only register we allow
 		// and DX is the only output we care about (the high bits)
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[1])
 
 		// IMULB puts the high portion in AH instead of DL,
 		// so move it to DL for consistency
 		if v.Type.Size() == 1 {
-			m := CreateProg(x86.AMOVB)
+			m := s.prog(x86.AMOVB)
 			m.From.Type = TYPE_REG
 			m.From.Reg = x86.REG_AH
 			m.To.Type = TYPE_REG
 			m.To.Reg = x86.REG_DX
 		}
-		progs = append(progs, p)
 	case ssa.OpAMD64SHLQ, ssa.OpAMD64SHLL,
-		ssa.OpAMD64SHRQ, ssa.OpAMD64SHRL,
-		ssa.OpAMD64SARQ, ssa.OpAMD64SARL:
+		ssa.OpAMD64SHRQ, ssa.OpAMD64SHRL, ssa.OpAMD64SHRW, ssa.OpAMD64SHRB,
+		ssa.OpAMD64SARQ, ssa.OpAMD64SARL, ssa.OpAMD64SARW, ssa.OpAMD64SARB:
 		x := regnum(v.Args[0])
 		r := regnum(v)
 		if x != r {
 			if r == x86.REG_CX {
 				v.Fatalf("can't implement %s, target and shift both in CX", v.LongString())
 			}
-			p = CreateProg(regMoveAMD64(v.Type.Size()))
+			p = s.prog(regMoveAMD64(v.Type.Size()))
 			p.From.Type = TYPE_REG
 			p.From.Reg = x
 			p.To.Type = TYPE_REG
 			p.To.Reg = r
 		}
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[1]) // should be CX
 		p.To.Type = TYPE_REG
 		p.To.Reg = r
-		progs = append(progs, p)
 	case ssa.OpAMD64ADDQconst, ssa.OpAMD64ADDLconst:
 		// TODO: use addq instead of leaq if target is in the right register.
 		var asm int
@@ -1008,24 +1057,23 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		case ssa.OpAMD64ADDLconst:
 			asm = x86.ALEAL
 		}
-		p = CreateProg(asm)
+		p = s.prog(asm)
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		p.From.Offset = v.AuxInt
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MULQconst, ssa.OpAMD64MULLconst:
 		r := regnum(v)
 		x := regnum(v.Args[0])
 		if r != x {
-			p = CreateProg(regMoveAMD64(v.Type.Size()))
+			p = s.prog(regMoveAMD64(v.Type.Size()))
 			p.From.Type = TYPE_REG
 			p.From.Reg = x
 			p.To.Type = TYPE_REG
 			p.To.Reg = r
 		}
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_CONST
 		p.From.Offset = v.AuxInt
 		p.To.Type = TYPE_REG
@@ -1035,43 +1083,40 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		//p.From3 = new(obj.Addr)
 		//p.From3.Type = TYPE_REG
 		//p.From3.Reg = regnum(v.Args[0])
-		progs = append(progs, p)
 	case
 		ssa.OpAMD64ANDQconst, ssa.OpAMD64ANDLconst,
 		ssa.OpAMD64ORQconst, ssa.OpAMD64ORLconst,
 		ssa.OpAMD64XORQconst, ssa.OpAMD64XORLconst,
 		ssa.OpAMD64SUBQconst, ssa.OpAMD64SUBLconst,
 		ssa.OpAMD64SHLQconst, ssa.OpAMD64SHLLconst,
-		ssa.OpAMD64SHRQconst, ssa.OpAMD64SHRLconst,
-		ssa.OpAMD64SARQconst, ssa.OpAMD64SARLconst,
-		ssa.OpAMD64ROLQconst, ssa.OpAMD64ROLLconst:
+		ssa.OpAMD64SHRQconst, ssa.OpAMD64SHRLconst, ssa.OpAMD64SHRWconst, ssa.OpAMD64SHRBconst,
+		ssa.OpAMD64SARQconst, ssa.OpAMD64SARLconst, ssa.OpAMD64SARWconst, ssa.OpAMD64SARBconst,
+		ssa.OpAMD64ROLQconst, ssa.OpAMD64ROLLconst, ssa.OpAMD64ROLWconst, ssa.OpAMD64ROLBconst:
 		// This code compensates for the fact that the register allocator
 		// doesn't understand 2-address instructions yet.  TODO: fix that.
 		x := regnum(v.Args[0])
 		r := regnum(v)
 		if x != r {
-			p = CreateProg(regMoveAMD64(v.Type.Size()))
+			p = s.prog(regMoveAMD64(v.Type.Size()))
 			p.From.Type = TYPE_REG
 			p.From.Reg = x
 			p.To.Type = TYPE_REG
 			p.To.Reg = r
 		}
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_CONST
 		p.From.Offset = v.AuxInt
 		p.To.Type = TYPE_REG
 		p.To.Reg = r
-		progs = append(progs, p)
 	case ssa.OpAMD64SBBQcarrymask, ssa.OpAMD64SBBLcarrymask:
 		r := regnum(v)
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = r
 		p.To.Type = TYPE_REG
 		p.To.Reg = r
-		progs = append(progs, p)
 	case ssa.OpAMD64LEAQ1, ssa.OpAMD64LEAQ2, ssa.OpAMD64LEAQ4, ssa.OpAMD64LEAQ8:
-		p = CreateProg(x86.ALEAQ)
+		p = s.prog(x86.ALEAQ)
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		switch v.Op {
@@ -1088,33 +1133,30 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		addAux(&p.From, v)
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64LEAQ:
-		p = CreateProg(x86.ALEAQ)
+		p = s.prog(x86.ALEAQ)
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		addAux(&p.From, v)
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64CMPQ, ssa.OpAMD64CMPL, ssa.OpAMD64CMPW, ssa.OpAMD64CMPB,
 		ssa.OpAMD64TESTQ, ssa.OpAMD64TESTL, ssa.OpAMD64TESTW, ssa.OpAMD64TESTB:
-		opregreg(int(v.Op.Asm()), regnum(v.Args[1]), regnum(v.Args[0]))
+		s.opregreg(int(v.Op.Asm()), regnum(v.Args[1]), regnum(v.Args[0]))
 	case ssa.OpAMD64UCOMISS, ssa.OpAMD64UCOMISD:
 		// Go assembler has swapped operands for UCOMISx relative to CMP,
 		// must account for that right here.
-		opregreg(int(v.Op.Asm()), regnum(v.Args[0]), regnum(v.Args[1]))
+		s.opregreg(int(v.Op.Asm()), regnum(v.Args[0]), regnum(v.Args[1]))
 	case ssa.OpAMD64CMPQconst, ssa.OpAMD64CMPLconst, ssa.OpAMD64CMPWconst, ssa.OpAMD64CMPBconst,
 		ssa.OpAMD64TESTQconst, ssa.OpAMD64TESTLconst, ssa.OpAMD64TESTWconst, ssa.OpAMD64TESTBconst:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[0])
 		p.To.Type = TYPE_CONST
 		p.To.Offset = v.AuxInt
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVLconst, ssa.OpAMD64MOVQconst:
 		x := regnum(v)
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_CONST
 		var i int64
 		switch v.Op {
@@ -1126,25 +1168,22 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		p.From.Offset = i
 		p.To.Type = TYPE_REG
 		p.To.Reg = x
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVSSconst, ssa.OpAMD64MOVSDconst:
 		x := regnum(v)
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_FCONST
 		p.From.Val = math.Float64frombits(uint64(v.AuxInt))
 		p.To.Type = TYPE_REG
 		p.To.Reg = x
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVQload, ssa.OpAMD64MOVSSload, ssa.OpAMD64MOVSDload, ssa.OpAMD64MOVLload, ssa.OpAMD64MOVWload, ssa.OpAMD64MOVBload, ssa.OpAMD64MOVBQSXload, ssa.OpAMD64MOVOload:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		addAux(&p.From, v)
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVQloadidx8, ssa.OpAMD64MOVSDloadidx8:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		addAux(&p.From, v)
@@ -1152,9 +1191,8 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		p.From.Index = regnum(v.Args[1])
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVSSloadidx4:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_MEM
 		p.From.Reg = regnum(v.Args[0])
 		addAux(&p.From, v)
@@ -1162,17 +1200,15 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		p.From.Index = regnum(v.Args[1])
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVQstore, ssa.OpAMD64MOVSSstore, ssa.OpAMD64MOVSDstore, ssa.OpAMD64MOVLstore, ssa.OpAMD64MOVWstore, ssa.OpAMD64MOVBstore, ssa.OpAMD64MOVOstore:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[1])
 		p.To.Type = TYPE_MEM
 		p.To.Reg = regnum(v.Args[0])
 		addAux(&p.To, v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVQstoreidx8, ssa.OpAMD64MOVSDstoreidx8:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[2])
 		p.To.Type = TYPE_MEM
@@ -1180,9 +1216,8 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		p.To.Scale = 8
 		p.To.Index = regnum(v.Args[1])
 		addAux(&p.To, v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVSSstoreidx4:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[2])
 		p.To.Type = TYPE_MEM
@@ -1190,9 +1225,8 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		p.To.Scale = 4
 		p.To.Index = regnum(v.Args[1])
 		addAux(&p.To, v)
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVQstoreconst, ssa.OpAMD64MOVLstoreconst, ssa.OpAMD64MOVWstoreconst, ssa.OpAMD64MOVBstoreconst:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_CONST
 		sc := ssa.ValAndOff(v.AuxInt)
 		i := sc.Val()
@@ -1208,32 +1242,28 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		p.From.Offset = i
 		p.To.Type = TYPE_MEM
 		p.To.Reg = regnum(v.Args[0])
-		fmt.Println("P.TO.REG:", Rconv(int(p.To.Reg)))
 		addAux2(&p.To, v, sc.Off())
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVLQSX, ssa.OpAMD64MOVWQSX, ssa.OpAMD64MOVBQSX, ssa.OpAMD64MOVLQZX, ssa.OpAMD64MOVWQZX, ssa.OpAMD64MOVBQZX,
 		ssa.OpAMD64CVTSL2SS, ssa.OpAMD64CVTSL2SD, ssa.OpAMD64CVTSQ2SS, ssa.OpAMD64CVTSQ2SD,
 		ssa.OpAMD64CVTTSS2SL, ssa.OpAMD64CVTTSD2SL, ssa.OpAMD64CVTTSS2SQ, ssa.OpAMD64CVTTSD2SQ,
 		ssa.OpAMD64CVTSS2SD, ssa.OpAMD64CVTSD2SS:
-		opregreg(int(v.Op.Asm()), regnum(v), regnum(v.Args[0]))
+		s.opregreg(int(v.Op.Asm()), regnum(v), regnum(v.Args[0]))
 	case ssa.OpAMD64DUFFZERO:
-		p = CreateProg(obj.ADUFFZERO)
+		p = s.prog(obj.ADUFFZERO)
 		p.To.Type = TYPE_ADDR
 		//p.To.Sym = Linksym(Pkglookup("duffzero", Runtimepkg))
 		p.To.Offset = v.AuxInt
-		progs = append(progs, p)
 	case ssa.OpAMD64MOVOconst:
 		if v.AuxInt != 0 {
 			v.Fatalf("MOVOconst can only do constant=0")
 		}
 		r := regnum(v)
-		opregreg(x86.AXORPS, r, r)
+		s.opregreg(x86.AXORPS, r, r)
 	case ssa.OpAMD64DUFFCOPY:
-		p = CreateProg(obj.ADUFFCOPY)
+		p = s.prog(obj.ADUFFCOPY)
 		p.To.Type = TYPE_ADDR
 		//p.To.Sym = Linksym(Pkglookup("duffcopy", Runtimepkg))
 		p.To.Offset = v.AuxInt
-		progs = append(progs, p)
 	case ssa.OpCopy: // TODO: lower to MOVQ earlier?
 		if v.Type.IsMemory() {
 			panic("unimplementedf")
@@ -1242,7 +1272,7 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		x := regnum(v.Args[0])
 		y := regnum(v)
 		if x != y {
-			opregreg(regMoveByTypeAMD64(v.Type), y, x)
+			s.opregreg(regMoveByTypeAMD64(v.Type), y, x)
 		}
 	case ssa.OpLoadReg:
 		if v.Type.IsFlags() {
@@ -1250,42 +1280,40 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 			panic("unimplementedf")
 			//return
 		}
-		p = CreateProg(movSizeByType(v.Type))
+		p = s.prog(movSizeByType(v.Type))
 		n, off := autoVar(v.Args[0])
 		p.From.Type = TYPE_MEM
 		p.From.Node = n
 		//p.From.Sym = Linksym(n.Sym)
-		p.From.Offset = off
+		p.From.Offset = off + n.Xoffset()
 		if n.Class() == PPARAM {
 			p.From.Name = NAME_PARAM
-			p.From.Offset += n.Xoffset()
+			p.From.Sym = &LSym{Name: asmName(n)}
 		} else {
 			p.From.Name = NAME_AUTO
 		}
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpStoreReg:
 		if v.Type.IsFlags() {
 			v.Fatalf("store flags not implemented: %v", v.LongString())
 			panic("unimplementedf")
 			//return
 		}
-		p = CreateProg(movSizeByType(v.Type))
+		p = s.prog(movSizeByType(v.Type))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[0])
 		n, off := autoVar(v)
 		p.To.Type = TYPE_MEM
 		p.To.Node = n
 		//p.To.Sym = Linksym(n.Sym)
-		p.To.Offset = off
+		p.To.Offset = off + n.Xoffset()
 		if n.Class() == PPARAM {
 			p.To.Name = NAME_PARAM
-			p.To.Offset += n.Xoffset()
+			p.To.Sym = &LSym{Name: asmName(n)}
 		} else {
 			p.To.Name = NAME_AUTO
 		}
-		progs = append(progs, p)
 	case ssa.OpPhi:
 		// just check to make sure regalloc and stackalloc did it right
 		if v.Type.IsMemory() {
@@ -1301,10 +1329,6 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		}
 	case ssa.OpConst8, ssa.OpConst16, ssa.OpConst32, ssa.OpConst64, ssa.OpConstString, ssa.OpConstNil, ssa.OpConstBool,
 		ssa.OpConst32F, ssa.OpConst64F:
-		fmt.Println("v.ID:", v.ID)
-		f := v.Block.Func
-		fmt.Println("f.RegAlloc:", f.RegAlloc)
-		fmt.Println("len(f.RegAlloc):", len(f.RegAlloc))
 		if v.Block.Func.RegAlloc[v.ID] != nil {
 			v.Fatalf("const value %v shouldn't have a location", v)
 		}
@@ -1330,35 +1354,32 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 	case ssa.OpAMD64CALLgo:
 		panic("unimplementedf")
 	case ssa.OpAMD64CALLinter:
-		p = CreateProg(obj.ACALL)
+		p = s.prog(obj.ACALL)
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v.Args[0])
 		if Maxarg < v.AuxInt {
 			Maxarg = v.AuxInt
 		}
-		progs = append(progs, p)
 	case ssa.OpAMD64NEGQ, ssa.OpAMD64NEGL,
 		ssa.OpAMD64NOTQ, ssa.OpAMD64NOTL:
 		x := regnum(v.Args[0])
 		r := regnum(v)
 		if x != r {
-			p = CreateProg(regMoveAMD64(v.Type.Size()))
+			p = s.prog(regMoveAMD64(v.Type.Size()))
 			p.From.Type = TYPE_REG
 			p.From.Reg = x
 			p.To.Type = TYPE_REG
 			p.To.Reg = r
 		}
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.To.Type = TYPE_REG
 		p.To.Reg = r
-		progs = append(progs, p)
 	case ssa.OpAMD64SQRTSD:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.From.Type = TYPE_REG
 		p.From.Reg = regnum(v.Args[0])
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpSP, ssa.OpSB:
 		// nothing to do
 	case ssa.OpAMD64SETEQ, ssa.OpAMD64SETNE,
@@ -1368,42 +1389,35 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		ssa.OpAMD64SETB, ssa.OpAMD64SETBE,
 		ssa.OpAMD64SETORD, ssa.OpAMD64SETNAN,
 		ssa.OpAMD64SETA, ssa.OpAMD64SETAE:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		progs = append(progs, p)
 	case ssa.OpAMD64SETNEF:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		q := CreateProg(x86.ASETPS)
+		q := s.prog(x86.ASETPS)
 		q.To.Type = TYPE_REG
 		q.To.Reg = x86.REG_AX
 		// TODO AORQ copied from old code generator, why not AORB?
-		opregreg(x86.AORQ, regnum(v), x86.REG_AX)
-		progs = append(progs, p)
+		s.opregreg(x86.AORQ, regnum(v), x86.REG_AX)
 	case ssa.OpAMD64SETEQF:
-		p = CreateProg(int(v.Op.Asm()))
+		p = s.prog(int(v.Op.Asm()))
 		p.To.Type = TYPE_REG
 		p.To.Reg = regnum(v)
-		q := CreateProg(x86.ASETPC)
+		q := s.prog(x86.ASETPC)
 		q.To.Type = TYPE_REG
 		q.To.Reg = x86.REG_AX
 		// TODO AANDQ copied from old code generator, why not AANDB?
-		opregreg(x86.AANDQ, regnum(v), x86.REG_AX)
-		progs = append(progs, p)
+		s.opregreg(x86.AANDQ, regnum(v), x86.REG_AX)
 	case ssa.OpAMD64InvertFlags:
 		v.Fatalf("InvertFlags should never make it to codegen %v", v)
 	case ssa.OpAMD64REPSTOSQ:
-		p := CreateProg(x86.AREP)
-		q := CreateProg(x86.ASTOSQ)
-		progs = append(progs, p)
-		progs = append(progs, q)
+		s.prog(x86.AREP)
+		s.prog(x86.ASTOSQ)
 	case ssa.OpAMD64REPMOVSQ:
-		p := CreateProg(x86.AREP)
-		q := CreateProg(x86.AMOVSQ)
-		progs = append(progs, p)
-		progs = append(progs, q)
+		s.prog(x86.AREP)
+		s.prog(x86.AMOVSQ)
 	case ssa.OpVarDef:
 		panic("unimplementedf")
 		//Gvardef(v.Aux.(*Node))
@@ -1446,20 +1460,18 @@ func (s *genState) genValue(v *ssa.Value) []*Prog {
 		// but it doesn't have false dependency on AX.
 		// Or maybe allocate an output register and use MOVL (reg),reg2 ?
 		// That trades clobbering flags for clobbering a register.
-		p = CreateProg(x86.ATESTB)
+		p = s.prog(x86.ATESTB)
 		p.From.Type = TYPE_REG
 		p.From.Reg = x86.REG_AX
 		p.To.Type = TYPE_MEM
 		p.To.Reg = regnum(v.Args[0])
 		addAux(&p.To, v)
-		progs = append(progs, p)
 	default:
-		fmt.Println("unimplemented OP:", v.Op.String())
 		v.Fatalf("genValue not implemented: %s", v.LongString())
 		panic("unimplementedf")
 
 	}
-	return progs
+	return s.progs
 }
 
 // movSizeByType returns the MOV instruction of the given type.
@@ -1501,7 +1513,7 @@ var blockJump = [...]struct {
 	ssa.BlockAMD64NAN: {x86.AJPS, x86.AJPC},
 }
 
-/*type floatingEQNEJump struct {
+type floatingEQNEJump struct {
 	jump, index int
 }
 
@@ -1513,48 +1525,29 @@ var nefJumps = [2][2]floatingEQNEJump{
 	{{x86.AJNE, 0}, {x86.AJPC, 1}}, // next == b.Succs[0]
 	{{x86.AJNE, 0}, {x86.AJPS, 0}}, // next == b.Succs[1]
 }
-*/
-
-/*func oneFPJump(b *ssa.Block, jumps *floatingEQNEJump, likely ssa.BranchPrediction, branches []branch) []branch {
-	p := Prog(jumps.jump)
-	p.To.Type = TYPE_BRANCH
-	to := jumps.index
-	branches = append(branches, branch{p, b.Succs[to]})
-	if to == 1 {
-		likely = -likely
+
+// genFPJump returns the jumps of the block, b, ending with a float == or
+// !=. Unordered floats, NaNs, set the parity flag, so it's two jumps.
+func (s *genState) genFPJump(b, next *ssa.Block, jumps *[2][2]floatingEQNEJump) []*Prog {
+	var progs []*Prog
+	js := &jumps[1]
+	if next == b.Succs[0].Block() {
+		js = &jumps[0]
 	}
-	// liblink reorders the instruction stream as it sees fit.
-	// Pass along what we know so liblink can make use of it.
-	// TODO: Once we've fully switched to SSA,
-	// make liblink leave our output alone.
-	switch likely {
-	case ssa.BranchUnlikely:
-		p.From.Type = TYPE_CONST
-		p.From.Offset = 0
-	case ssa.BranchLikely:
-		p.From.Type = TYPE_CONST
-		p.From.Offset = 1
+	for _, j := range js {
+		p := CreateProg(j.jump)
+		p.To.Type = TYPE_BRANCH
+		s.branches = append(s.branches, branch{p, b.Succs[j.index].Block()})
+		progs = append(progs, p)
 	}
-	return branches
-}*/
-
-/*func genFPJump(s *genState, b, next *ssa.Block, jumps *[2][2]floatingEQNEJump) {
-	likely := b.Likely
-	switch next {
-	case b.Succs[0]:
-		s.branches = oneFPJump(b, &jumps[0][0], likely, s.branches)
-		s.branches = oneFPJump(b, &jumps[0][1], likely, s.branches)
-	case b.Succs[1]:
-		s.branches = oneFPJump(b, &jumps[1][0], likely, s.branches)
-		s.branches = oneFPJump(b, &jumps[1][1], likely, s.branches)
-	default:
-		s.branches = oneFPJump(b, &jumps[1][0], likely, s.branches)
-		s.branches = oneFPJump(b, &jumps[1][1], likely, s.branches)
-		q := Prog(obj.AJMP)
+	if next != b.Succs[0].Block() && next != b.Succs[1].Block() {
+		q := CreateProg(obj.AJMP)
 		q.To.Type = TYPE_BRANCH
-		s.branches = append(s.branches, branch{q, b.Succs[1]})
+		s.branches = append(s.branches, branch{q, b.Succs[1].Block()})
+		progs = append(progs, q)
 	}
-}*/
+	return progs
+}
 
 func (s *genState) genBlock(b, next *ssa.Block) []*Prog {
 	var progs []*Prog
@@ -1583,25 +1576,22 @@ func (s *genState) genBlock(b, next *ssa.Block) []*Prog {
 		progs = append(progs, p)
 
 	case ssa.BlockAMD64EQF:
-		panic("unimplementedf")
-		//genFPJump(s, b, next, &eqfJumps)
+		progs = append(progs, s.genFPJump(b, next, &eqfJumps)...)
 
 	case ssa.BlockAMD64NEF:
-		panic("unimplementedf")
-		//genFPJump(s, b, next, &nefJumps)
+		progs = append(progs, s.genFPJump(b, next, &nefJumps)...)
 
 	case ssa.BlockAMD64EQ, ssa.BlockAMD64NE,
 		ssa.BlockAMD64LT, ssa.BlockAMD64GE,
 		ssa.BlockAMD64LE, ssa.BlockAMD64GT,
 		ssa.BlockAMD64ULT, ssa.BlockAMD64UGT,
-		ssa.BlockAMD64ULE, ssa.BlockAMD64UGE:
+		ssa.BlockAMD64ULE, ssa.BlockAMD64UGE,
+		ssa.BlockAMD64ORD, ssa.BlockAMD64NAN:
 		jmp := blockJump[b.Kind]
-		likely := b.Likely
-		var p *Prog
+		var p, q *Prog
 		switch next {
 		case b.Succs[0].Block():
 			p = CreateProg(jmp.invasm)
-			likely *= -1
 			p.To.Type = TYPE_BRANCH
 			s.branches = append(s.branches, branch{p, b.Succs[1].Block()})
 		case b.Succs[1].Block():
@@ -1612,24 +1602,17 @@ func (s *genState) genBlock(b, next *ssa.Block) []*Prog {
 			p = CreateProg(jmp.asm)
 			p.To.Type = TYPE_BRANCH
 			s.branches = append(s.branches, branch{p, b.Succs[0].Block()})
-			q := CreateProg(obj.AJMP)
+			q = CreateProg(obj.AJMP)
 			q.To.Type = TYPE_BRANCH
 			s.branches = append(s.branches, branch{q, b.Succs[1].Block()})
 		}
 
-		// liblink reorders the instruction stream as it sees fit.
-		// Pass along what we know so liblink can make use of it.
-		// TODO: Once we've fully switched to SSA,
-		// make liblink leave our output alone.
-		switch likely {
-		case ssa.BranchUnlikely:
-			p.From.Type = TYPE_CONST
-			p.From.Offset = 0
-		case ssa.BranchLikely:
-			p.From.Type = TYPE_CONST
-			p.From.Offset = 1
-		}
+		// The likeliness of the branch isn't passed along, the Go
+		// assembler has no syntax for it on amd64.
 		progs = append(progs, p)
+		if q != nil {
+			progs = append(progs, q)
+		}
 	default:
 		panic("unimplemented")
 		//b.Unimplementedf("branch not implemented: %s. Control: %s", b.LongString(), b.Control.LongString())
@@ -1679,14 +1662,14 @@ func addAux2(a *Addr, v *ssa.Value, offset int64) {
 		n := sym.Node.(ssaVar)
 		a.Name = NAME_PARAM
 		a.Node = n
-		a.Sym = &LSym{} //Linksym(n.Orig.Sym)
-		a.Sym.Name = n.Name()
-		a.Offset += n.Xoffset() // TODO: why do I have to add this here?  I don't for auto variables.
+		a.Sym = &LSym{Name: asmName(n)} //Linksym(n.Orig.Sym)
+		a.Offset += n.Xoffset()
 	case *ssa.AutoSymbol:
 		n := sym.Node.(ssaVar)
 		a.Name = NAME_AUTO
 		a.Node = n
 		//a.Sym = Linksym(n.Sym)
+		a.Offset += n.Xoffset()
 	default:
 		v.Fatalf("aux in %s not implemented %#v", v, v.Aux)
 	}
@@ -9,15 +9,21 @@ import (
 	"github.com/bjwbell/cmd/obj"
 	"github.com/bjwbell/gir/gimporter"
 	"github.com/bjwbell/gir/gst"
+	"github.com/bjwbell/gir/scan"
 	"github.com/bjwbell/ssa"
 )
 
-func TypeCheckFn(fnDecl *gst.FuncDecl, log bool) (function *types.Func, er error) {
-	function, ok := gimporter.ParseFuncDecl(fnDecl)
-	if !ok {
-		fmt.Printf("Error importing %v\n", fnDecl.Name)
-		er = fmt.Errorf("Error importing %v\n", fnDecl.Name)
-		return
+// TypeCheckFn converts the function, fnDecl, to go/ast and type checks it
+func TypeCheckFn(fnDecl *gst.FuncDecl, pkgName string) (decl *ast.FuncDecl, function *types.Func, info *types.Info, er error) {
+	decl, er = gimporter.FuncDecl(fnDecl)
+	if er == nil {
+		function, info, er = gimporter.Check(pkgName, decl)
+	}
+	if e, ok := er.(*scan.Error); ok {
+		// keep the position of the error in the function
+		er = &scan.Error{Pos: e.Pos, Msg: fmt.Sprintf("%v: %v", fnDecl.Name, e.Msg)}
+	} else if er != nil {
+		er = fmt.Errorf("%v: %v", fnDecl.Name, er)
 	}
 	return
 }
@@ -25,13 +31,26 @@ func TypeCheckFn(fnDecl *gst.FuncDecl, log bool) (function *types.Func, er error
 // BuildSSA parses the function, fn, which must be in ssa form and returns
 // the corresponding ssa.Func
 func BuildSSA(fnDecl *gst.FuncDecl, pkgName string, log bool) (ssafn *ssa.Func, usessa bool) {
-	function, err := TypeCheckFn(fnDecl, log)
+	ssafn, err := Build(fnDecl, pkgName, log)
 	if err != nil {
-		fmt.Println("Error in TypeCheckFn")
 		return nil, false
 	}
-	ssafn, ok := buildSSA(fnDecl, function, log)
-	return ssafn, ok
+	return ssafn, true
+}
+
+// Build is BuildSSA returning the error of the function, fnDecl. The
+// panics of the ssa package and of unimplemented features are errors.
+func Build(fnDecl *gst.FuncDecl, pkgName string, log bool) (ssafn *ssa.Func, err error) {
+	decl, function, info, err := TypeCheckFn(fnDecl, pkgName)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			ssafn, err = nil, fmt.Errorf("%v: %v", fnDecl.Name, r)
+		}
+	}()
+	return buildSSA(decl, function, info, log)
 }
 
 func getParameters(ctx Ctx, fn *types.Func) []*ssaParam {
@@ -42,7 +61,7 @@ func getParameters(ctx Ctx, fn *types.Func) []*ssaParam {
 	var params []*ssaParam
 	for i := 0; i < signature.Params().Len(); i++ {
 		param := signature.Params().At(i)
-		n := ssaParam{v: param, ctx: ctx}
+		n := ssaParam{v: param, ctx: ctx, sig: signature, index: i}
 		params = append(params, &n)
 	}
 	return params
@@ -56,7 +75,7 @@ func getReturnVar(ctx Ctx, fn *types.Func) []*ssaRetVar {
 	var results []*ssaRetVar
 	for i := 0; i < signature.Results().Len(); i++ {
 		ret := signature.Results().At(i)
-		n := ssaRetVar{v: ret, ctx: ctx}
+		n := ssaRetVar{v: ret, ctx: ctx, sig: signature, index: i}
 		results = append(results, &n)
 	}
 	return results
@@ -101,18 +120,15 @@ func getVars(ctx Ctx, fnDecl *ast.FuncDecl, fnType *types.Func) []ssaVar {
 		for _, local := range locals {
 			for _, ret := range results {
 				if p.Name() == local.Name() {
-					fmt.Printf("p.Name(): %v, local.Name(): %v\n", p.Name(), local.Name())
-					panic("param and local with same name")
+					panic(fmt.Sprintf("param and local with same name %v", p.Name()))
 				}
 
 				if p.Name() == ret.Name() {
-					fmt.Printf("p.Name(): %v, ret.Name(): %v\n", p.Name(), ret.Name())
-					panic("param and result value with same name")
+					panic(fmt.Sprintf("param and result value with same name %v", p.Name()))
 				}
 
 				if local.Name() == ret.Name() {
-					fmt.Printf("local.Name(): %v, ret.Name(): %v\n", local.Name(), ret.Name())
-					panic("local and result value with same name")
+					panic(fmt.Sprintf("local and result value with same name %v", local.Name()))
 				}
 			}
 
@@ -132,36 +148,35 @@ func getVars(ctx Ctx, fnDecl *ast.FuncDecl, fnType *types.Func) []ssaVar {
 	return vars
 }
 
-func buildSSA(fn *gst.FuncDecl, fnType *types.Func, log bool) (ssafn *ssa.Func, ok bool) {
+func buildSSA(fn *ast.FuncDecl, fnType *types.Func, fnInfo *types.Info, log bool) (*ssa.Func, error) {
 
 	// HACK, hardcoded
 	arch := "amd64"
 
 	signature, ok := fnType.Type().(*types.Signature)
 	if signature == nil || !ok {
-		fmt.Println("Error: function type isn't signature")
-		return nil, false
+		return nil, fmt.Errorf("%v: function type isn't signature", fnType.Name())
 	}
 
 	if signature.Results().Len() > 1 {
-		fmt.Println("Multiple return values unsupported")
-		return nil, false
+		return nil, fmt.Errorf("%v: multiple return values unsupported", fnType.Name())
 	}
 
 	var e ssaExport
 	var s state
 	e.log = log
 	link := obj.Link{}
-	s.ctx = Ctx{nil} //Ctx{fnInfo}
-	s.fnDecl = nil
-	s.fnType = nil
-	s.fnInfo = nil
+	s.ctx = Ctx{fnInfo}
+	s.fnDecl = fn
+	s.fnType = fnType
+	s.fnInfo = fnInfo
 	s.config = ssa.NewConfig(arch, &e, &link, false)
 	s.f = s.config.NewFunc()
 	s.f.Name = fnType.Name()
-	s.f.Entry = s.f.NewBlock(ssa.BlockPlain)
+	s.f.Type = &Type{signature}
+	s.explicitMem = memOps(fn.Body)
 
-	s.scanBlocksGst(fn.Body)
+	s.scanBlocks(fn.Body)
 	if len(s.blocks) < 1 {
 		panic("no blocks found, need at least one block per function")
 	}
@@ -179,6 +194,12 @@ func buildSSA(fn *gst.FuncDecl, fnType *types.Func, log bool) (ssafn *ssa.Func,
 
 	s.vars = map[ssaVar]*ssa.Value{}
 	s.vars[&memVar] = s.startmem
+	s.objVars = map[types.Object]ssaVar{}
+	if ret := s.retVar(); ret != nil {
+		// like Go, the result starts as the zero value
+		retVar := s.objVar(ret.v)
+		s.vars[retVar] = s.zeroVal(retVar.Typ().(*Type))
+	}
 
 	//s.varsyms = map[*Node]interface{}{}
 
@@ -219,6 +240,7 @@ func buildSSA(fn *gst.FuncDecl, fnType *types.Func, log bool) (ssafn *ssa.Func,
 	s.decladdrs[nodfp] = s.entryNewValue1A(ssa.OpAddr, Typ[types.Uintptr], aux, s.sp)
 
 	s.processBlocks()
+	s.linkPhis()
 
 	// Link up variable uses to variable definitions
 	s.linkForwardReferences()
@@ -227,5 +249,5 @@ func buildSSA(fn *gst.FuncDecl, fnType *types.Func, log bool) (ssafn *ssa.Func,
 
 	ssa.Compile(s.f)
 
-	return s.f, true
+	return s.f, nil
 }
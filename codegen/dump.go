@@ -0,0 +1,260 @@
+package codegen
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/bjwbell/cmd/obj"
+	"github.com/bjwbell/cmd/src"
+	"github.com/bjwbell/gir/dump"
+	"github.com/bjwbell/gir/gimporter"
+	"github.com/bjwbell/ssa"
+)
+
+// dumpState is the state of building the ssa.Func of a dump.
+type dumpState struct {
+	fn     *dump.Func
+	f      *ssa.Func
+	blocks map[int]*ssa.Block
+	values map[int]*ssa.Value
+	vars   map[string]ssaVar
+}
+
+// BuildDump builds the SSA function of the GOSSAFUNC dump, fn. The dump
+// is of a pass before regalloc, the function is compiled again.
+func BuildDump(fn *dump.Func, log bool) (ssafn *ssa.Func, err error) {
+	defer func() {
+		// the ssa package panics on invalid functions
+		if r := recover(); r != nil {
+			ssafn, err = nil, fmt.Errorf("%v: %v", fn.Name, r)
+		}
+	}()
+	if len(fn.Blocks) == 0 {
+		return nil, fmt.Errorf("%v: no blocks", fn.Name)
+	}
+	sig, err := dumpType(fn.Type)
+	if err != nil {
+		return nil, err
+	}
+	signature, ok := sig.(*Type).Type.(*types.Signature)
+	if !ok {
+		return nil, fmt.Errorf("%v: %v isn't a signature", fn.Name, fn.Type)
+	}
+	var e ssaExport
+	e.log = log
+	link := obj.Link{}
+	config := ssa.NewConfig("amd64", &e, &link, false)
+	d := &dumpState{
+		fn:     fn,
+		f:      config.NewFunc(),
+		blocks: map[int]*ssa.Block{},
+		values: map[int]*ssa.Value{},
+	}
+	d.f.Name = fn.Name
+	d.f.Type = sig
+	for _, b := range fn.Blocks {
+		kind, ok := gimporter.LookupBlockKind(b.Kind)
+		if !ok {
+			return nil, fmt.Errorf("%v: b%d: unknown block kind %v", fn.Name, b.ID, b.Kind)
+		}
+		if d.blocks[b.ID] != nil {
+			return nil, fmt.Errorf("%v: b%d redefined", fn.Name, b.ID)
+		}
+		d.blocks[b.ID] = d.f.NewBlock(kind)
+	}
+	d.f.Entry = d.blocks[fn.Blocks[0].ID]
+	if err := d.declareVars(signature); err != nil {
+		return nil, err
+	}
+	// values are created before their args are added,
+	// a phi uses values of later blocks
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if err := d.newValue(d.blocks[b.ID], v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			for _, arg := range v.Args {
+				a, err := d.value(v.Line, arg)
+				if err != nil {
+					return nil, err
+				}
+				d.values[v.ID].AddArg(a)
+			}
+		}
+		block := d.blocks[b.ID]
+		if b.Control != 0 {
+			c, err := d.value(b.Line, b.Control)
+			if err != nil {
+				return nil, err
+			}
+			block.SetControl(c)
+		}
+		switch b.Likely {
+		case 1:
+			block.Likely = ssa.BranchLikely
+		case -1:
+			block.Likely = ssa.BranchUnlikely
+		}
+	}
+	if err := d.addEdges(); err != nil {
+		return nil, err
+	}
+	ssa.Compile(d.f)
+	return d.f, nil
+}
+
+// dumpType returns the type printed in a dump, "int", "*int", "mem".
+func dumpType(s string) (ssa.Type, error) {
+	switch s {
+	case "mem":
+		return ssa.TypeMem, nil
+	case "flags":
+		return ssa.TypeFlags, nil
+	case "void":
+		return ssa.TypeVoid, nil
+	}
+	tv, err := types.Eval(token.NewFileSet(), nil, token.NoPos, s)
+	if err != nil {
+		return nil, fmt.Errorf("type %v: %v", s, err)
+	}
+	if !tv.IsType() {
+		return nil, fmt.Errorf("%v isn't a type", s)
+	}
+	return &Type{tv.Type}, nil
+}
+
+// declareVars declares the variables named by the aux of the Arg and
+// Addr values. The params and results of the signature, sig, are named
+// as Func.Vars finds them, the rest are locals with the type the Addr
+// points to.
+func (d *dumpState) declareVars(sig *types.Signature) error {
+	d.vars = map[string]ssaVar{}
+	var names []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		names = append(names, sig.Params().At(i).Name())
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		names = append(names, sig.Results().At(i).Name())
+	}
+	params := sig.Params().Len()
+	for n, aux := range d.fn.Vars(names, params) {
+		if aux == "" {
+			continue
+		}
+		if n < params {
+			param := sig.Params().At(n)
+			d.vars[aux] = &ssaParam{v: types.NewVar(0, nil, aux, param.Type()), sig: sig, index: n}
+		} else {
+			result := sig.Results().At(n - params)
+			d.vars[aux] = &ssaRetVar{v: types.NewVar(0, nil, aux, result.Type()), sig: sig, index: n - params}
+		}
+	}
+	for _, b := range d.fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op != "Arg" && v.Op != "Addr" || v.Aux == "" || d.vars[v.Aux] != nil {
+				continue
+			}
+			t, err := dumpType(v.Type)
+			if err != nil {
+				return fmt.Errorf("%v: v%d: %v", d.fn.Name, v.ID, err)
+			}
+			ptr, ok := t.(*Type).Type.(*types.Pointer)
+			if v.Op != "Addr" || !ok {
+				return fmt.Errorf("%v: v%d: %v isn't a param or result", d.fn.Name, v.ID, v.Aux)
+			}
+			d.vars[v.Aux] = &ssaLocal{obj: types.NewVar(0, nil, v.Aux, ptr.Elem())}
+		}
+	}
+	return nil
+}
+
+// newValue adds the value, v, without its args to the block, b.
+func (d *dumpState) newValue(b *ssa.Block, v *dump.Value) error {
+	errorf := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%v: v%d: %v", d.fn.Name, v.ID, fmt.Sprintf(format, args...))
+	}
+	if d.values[v.ID] != nil {
+		return errorf("redefined")
+	}
+	op, ok := gimporter.LookupOp(v.Op)
+	if !ok {
+		return errorf("unknown op %v", v.Op)
+	}
+	t, err := dumpType(v.Type)
+	if err != nil {
+		return errorf("%v", err)
+	}
+	value := b.NewValue0(src.XPos{}, op, t)
+	if v.AuxInt != "" {
+		if value.AuxInt, err = dump.ParseAuxInt(v.AuxInt); err != nil {
+			return errorf("%v", err)
+		}
+	}
+	if v.Aux != "" {
+		if value.Aux, err = d.aux(op, v.Aux); err != nil {
+			return errorf("%v", err)
+		}
+	}
+	d.values[v.ID] = value
+	return nil
+}
+
+// aux returns the aux of an op, a string or a variable, the symbol of
+// the variable for an Addr.
+func (d *dumpState) aux(op ssa.Op, s string) (interface{}, error) {
+	if str, err := strconv.Unquote(s); err == nil {
+		return str, nil
+	}
+	n := d.vars[s]
+	if n == nil {
+		return nil, fmt.Errorf("undefined: %v", s)
+	}
+	return auxVar(op, n), nil
+}
+
+func (d *dumpState) value(line, id int) (*ssa.Value, error) {
+	v := d.values[id]
+	if v == nil {
+		return nil, fmt.Errorf("%v: line %d: undefined v%d", d.fn.Name, line, id)
+	}
+	return v, nil
+}
+
+// addEdges adds the edges from the blocks to their successors. Adding an
+// edge appends it to both the successors of its block and the
+// predecessors of its successor, so the edges are added in an order
+// keeping both as in the dump, the phi args follow the predecessors.
+func (d *dumpState) addEdges() error {
+	next := map[int]int{} // index of the next predecessor of each block
+	done := map[int]int{} // successors added of each block
+	for added := true; added; {
+		added = false
+		for _, b := range d.fn.Blocks {
+			for done[b.ID] < len(b.Succs) {
+				succ := d.fn.Block(b.Succs[done[b.ID]])
+				if succ == nil {
+					return fmt.Errorf("%v: b%d: undefined successor b%d", d.fn.Name, b.ID, b.Succs[done[b.ID]])
+				}
+				if len(succ.Preds) > 0 && (next[succ.ID] >= len(succ.Preds) || succ.Preds[next[succ.ID]] != b.ID) {
+					break
+				}
+				d.blocks[b.ID].AddEdgeTo(d.blocks[succ.ID])
+				next[succ.ID]++
+				done[b.ID]++
+				added = true
+			}
+		}
+	}
+	for _, b := range d.fn.Blocks {
+		if done[b.ID] < len(b.Succs) {
+			return fmt.Errorf("%v: b%d: successors don't match the predecessors", d.fn.Name, b.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package codegen
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+
+	"github.com/bjwbell/gir/dump"
+	"github.com/bjwbell/ssa"
+)
+
+// GenGir returns the SSA function, f, as GIR source. f can be printed
+// after ssa.Compile or any of its passes, the GIR builds it again.
+func GenGir(f *ssa.Func) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("no function")
+	}
+	return dump.Gir(Dump(f))
+}
+
+// GenGirPass returns the SSA function, f, built by Build or BuildDump,
+// as GIR source as it was after the pass of ssa.Compile named pass, like
+// "opt" or "lower". The GIR of a pass before regalloc builds the same
+// function again.
+func GenGirPass(f *ssa.Func, pass string) (string, error) {
+	if f == nil {
+		return "", fmt.Errorf("no function")
+	}
+	e, ok := f.Config.Frontend().(*ssaExport)
+	if !ok || e.passes[pass] == nil {
+		return "", fmt.Errorf("%v: no pass %v", f.Name, pass)
+	}
+	return dump.Gir(e.passes[pass])
+}
+
+// Dump returns the SSA function, f, as it's printed in an SSA dump.
+func Dump(f *ssa.Func) *dump.Func {
+	fn := &dump.Func{Name: f.Name, Type: typeString(f.Type)}
+	// the entry block is first
+	blocks := []*ssa.Block{f.Entry}
+	for _, b := range f.Blocks {
+		if b != f.Entry {
+			blocks = append(blocks, b)
+		}
+	}
+	for _, b := range blocks {
+		block := &dump.Block{ID: int(b.ID), Kind: b.Kind.String()}
+		for _, e := range b.Preds {
+			block.Preds = append(block.Preds, int(e.Block().ID))
+		}
+		for _, v := range b.Values {
+			value := &dump.Value{
+				ID:   int(v.ID),
+				Op:   v.Op.String(),
+				Type: typeString(v.Type),
+				Aux:  auxString(v.Aux),
+			}
+			if v.AuxInt != 0 {
+				value.AuxInt = strconv.FormatInt(v.AuxInt, 10)
+			}
+			if int(v.ID) < len(f.RegAlloc) {
+				// a spill names its slot, "v9 = StoreReg <int> v5 : autotmp_0"
+				if slot, ok := f.RegAlloc[v.ID].(ssa.LocalSlot); ok && v.Aux == nil {
+					value.Aux = auxString(slot.N)
+				}
+			}
+			for _, arg := range v.Args {
+				value.Args = append(value.Args, int(arg.ID))
+			}
+			block.Values = append(block.Values, value)
+		}
+		if b.Control != nil {
+			block.Control = int(b.Control.ID)
+		}
+		for _, e := range b.Succs {
+			block.Succs = append(block.Succs, int(e.Block().ID))
+		}
+		switch b.Likely {
+		case ssa.BranchLikely:
+			block.Likely = 1
+		case ssa.BranchUnlikely:
+			block.Likely = -1
+		}
+		fn.Blocks = append(fn.Blocks, block)
+	}
+	return fn
+}
+
+// typeString returns the type, t, as it's printed in a dump, "*int".
+func typeString(t ssa.Type) string {
+	if t, ok := t.(*Type); ok {
+		return types.TypeString(t.Type, func(*types.Package) string { return "" })
+	}
+	return t.String()
+}
+
+// auxString returns the aux, aux, as it's printed in a dump, the name
+// of a variable or symbol or a quoted string.
+func auxString(aux interface{}) string {
+	switch aux := aux.(type) {
+	case nil:
+		return ""
+	case string:
+		return strconv.Quote(aux)
+	case ssaVar:
+		return aux.Name()
+	case *ssa.ArgSymbol:
+		return auxString(aux.Node)
+	case *ssa.AutoSymbol:
+		return auxString(aux.Node)
+	}
+	return fmt.Sprint(aux)
+}
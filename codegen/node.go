@@ -24,11 +24,9 @@ func (n *Node) String() string {
 func (n *Node) Typ() ssa.Type {
 	switch node := n.node.(type) {
 	case *ast.Ident:
-		t := &Type{n.ctx.fn.ObjectOf(node).Type()}
-		return t
+		return ssaType(n.ctx.fn.ObjectOf(node).Type())
 	case ast.Expr:
-		t := &Type{n.ctx.fn.TypeOf(node)}
-		return t
+		return ssaType(n.ctx.fn.TypeOf(node))
 	default:
 		panic("can't get type of node")
 	}
@@ -1,13 +1,72 @@
 package codegen
 
-import "github.com/bjwbell/ssa"
+import (
+	"go/ast"
+	"strconv"
 
-type OpValue interface {
-}
+	"github.com/bjwbell/gir/gimporter"
+	"github.com/bjwbell/ssa"
+)
 
 // SSE2 types
 type M128i [16]byte
 type M128 [4]float32
 type M128d [2]float64
 
-func Op2(op ssa.Op, src OpValue, dst OpValue) { panic("unreachable") }
+// op adds the SSA op, "Add64 <int64> v2 v3", to the current block.
+func (s *state) op(op *gimporter.Op) *ssa.Value {
+	o, ok := gimporter.LookupOp(op.Name)
+	if !ok {
+		s.Errorf("unknown op %v", op.Name)
+	}
+	// the function has one of each starting value
+	switch o {
+	case ssa.OpInitMem:
+		return s.startmem
+	case ssa.OpSP:
+		return s.sp
+	case ssa.OpSB:
+		return s.sb
+	}
+	var args []*ssa.Value
+	for _, arg := range op.Args {
+		args = append(args, s.expr(ExprNode(arg, s.ctx)))
+	}
+	v := s.newValue0(o, ExprNode(op.Call, s.ctx).Typ())
+	v.AuxInt = op.AuxInt
+	if op.Aux != nil {
+		v.Aux = auxVar(o, s.ssaVar(ExprNode(op.Aux, s.ctx)))
+	} else if op.AuxString != "" {
+		// checked by gimporter.Check
+		v.Aux, _ = strconv.Unquote(op.AuxString)
+	}
+	v.AddArgs(args...)
+	return v
+}
+
+// auxVar returns the aux of an op naming the variable n, the symbol of
+// n for an Addr.
+func auxVar(op ssa.Op, n ssaVar) interface{} {
+	if op != ssa.OpAddr {
+		return n
+	}
+	if n.Class() == PAUTO {
+		return &ssa.AutoSymbol{Typ: n.Typ(), Node: n}
+	}
+	return &ssa.ArgSymbol{Typ: n.Typ(), Node: n}
+}
+
+// memOps reports whether body has ops of type mem. Its memory is then
+// threaded through them, as in an SSA dump, and not stored to by return.
+func memOps(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if expr, ok := n.(ast.Expr); ok {
+			if op, ok := gimporter.IsOp(expr); ok && op.Type == "mem" {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
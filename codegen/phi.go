@@ -1,5 +1,38 @@
 package codegen
 
-func phi(vars map[string]int) int {
-	panic("dummy phi func")
+import (
+	"go/ast"
+
+	"github.com/bjwbell/ssa"
+)
+
+// explicitPhi is a phi written in the source, "x3 = phi(x1, x2)".
+// Its arguments are added once all the predecessors of its block
+// are known.
+type explicitPhi struct {
+	v    *ssa.Value
+	args []ast.Expr
+}
+
+// phi adds a phi value of type t for the phi call to the current block.
+func (s *state) phi(call *ast.CallExpr, t ssa.Type) *ssa.Value {
+	v := s.newValue0(ssa.OpPhi, t)
+	s.phis = append(s.phis, explicitPhi{v: v, args: call.Args})
+	return v
+}
+
+// linkPhis adds the arguments of the explicit phis, the ith argument is
+// the variable's value at the end of the ith predecessor of the block.
+// The predecessors are in the order of the branches to the block.
+func (s *state) linkPhis() {
+	for _, phi := range s.phis {
+		b := phi.v.Block
+		if len(phi.args) != len(b.Preds) {
+			s.Errorf("phi in %v has %v arguments, expected %v for its predecessors", b, len(phi.args), len(b.Preds))
+		}
+		for i, arg := range phi.args {
+			name := s.ssaVar(ExprNode(arg, s.ctx))
+			phi.v.AddArg(s.lookupVarOutgoing(b.Preds[i].Block(), phi.v.Type, name))
+		}
+	}
 }
@@ -1,15 +1,85 @@
 package codegen
 
 import (
+	"bytes"
 	"go/types"
-	"strings"
 )
 
-func GoProto(fn *types.Func) (string, string, string) {
-	pkgname := "package " + fn.Pkg().Name() + "\n"
-	imports := ""
-	signature := fn.Type().(*types.Signature)
-	sig := strings.TrimPrefix(signature.String(), "func(")
-	fnproto := "func " + fn.Name() + "(" + sig + "\n"
-	return pkgname, imports, fnproto
+// GoProto returns the Go declaration of the assembly function, name,
+// with the signature, sig, "func Add(x, y int64) int64". Consecutive
+// params of the same type share it, as in Go. The assembly doesn't keep
+// its args, so a function with pointers in its params is //go:noescape.
+func GoProto(name string, sig *types.Signature) string {
+	var buf bytes.Buffer
+	if paramPointers(sig) {
+		buf.WriteString("//go:noescape\n")
+	}
+	buf.WriteString("func " + name)
+	buf.WriteString(protoTuple(sig.Params()))
+	if results := sig.Results(); results.Len() == 1 && results.At(0).Name() == "" {
+		buf.WriteString(" " + protoType(results.At(0).Type()))
+	} else if results.Len() > 0 {
+		buf.WriteString(" " + protoTuple(results))
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// protoTuple returns the params or results, tuple, in parentheses.
+func protoTuple(tuple *types.Tuple) string {
+	var buf bytes.Buffer
+	buf.WriteString("(")
+	for i := 0; i < tuple.Len(); i++ {
+		v := tuple.At(i)
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if v.Name() == "" {
+			buf.WriteString(protoType(v.Type()))
+			continue
+		}
+		buf.WriteString(v.Name())
+		if i+1 < tuple.Len() && tuple.At(i+1).Name() != "" && types.Identical(v.Type(), tuple.At(i+1).Type()) {
+			// the next param has the same type
+			continue
+		}
+		buf.WriteString(" " + protoType(v.Type()))
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+// protoType returns the type, t, as it's written in Go.
+func protoType(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string { return pkg.Name() })
+}
+
+// paramPointers reports whether the params of the signature, sig, have
+// pointers.
+func paramPointers(sig *types.Signature) bool {
+	for i := 0; i < sig.Params().Len(); i++ {
+		if hasPointers(sig.Params().At(i).Type()) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPointers reports whether values of the type, t, have pointers.
+func hasPointers(t types.Type) bool {
+	switch t := t.Underlying().(type) {
+	case *types.Basic:
+		return t.Kind() == types.String || t.Kind() == types.UnsafePointer
+	case *types.Array:
+		return t.Len() > 0 && hasPointers(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasPointers(t.Field(i).Type()) {
+				return true
+			}
+		}
+		return false
+	}
+	// pointers, slices, maps, chans, funcs and interfaces
+	return true
 }
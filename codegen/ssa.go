@@ -8,7 +8,7 @@ import (
 	"go/types"
 
 	"github.com/bjwbell/cmd/src"
-	"github.com/bjwbell/gir/gst"
+	"github.com/bjwbell/gir/gimporter"
 	"github.com/bjwbell/ssa"
 )
 
@@ -64,6 +64,17 @@ type state struct {
 	// all defined variables at the end of each block.  Indexed by block ID.
 	defvars []map[ssaVar]*ssa.Value
 
+	// the variable of each parameter, result and local, so that
+	// every use of an object maps to the same key in vars
+	objVars map[types.Object]ssaVar
+
+	// phis written in the source, linked after all blocks are built
+	phis []explicitPhi
+
+	// whether the source threads memory through ops of type mem,
+	// return then leaves storing the result to them
+	explicitMem bool
+
 	// addresses of PPARAM and PPARAMOUT variables.
 	decladdrs map[ssaVar]*ssa.Value
 
@@ -120,9 +131,6 @@ func (s *state) Logf(msg string, args ...interface{}) { s.config.Logf(msg, args.
 
 func (s *state) Fatalf(msg string, args ...interface{}) { s.config.Fatalf(src.XPos{}, msg, args...) }
 func (s *state) Unimplementedf(msg string, args ...interface{}) {
-	// TODO: comment/remove when no longer needed for debugging
-	fmt.Printf("s.UNIMPLEMENTED msg: %v\n", fmt.Sprintf(msg, args))
-
 	s.config.Fatalf(src.XPos{}, msg, args...)
 }
 
@@ -147,7 +155,7 @@ func (s *state) startBlock(b *ssa.Block) {
 		s.Fatalf("starting block %v when block %v has not ended", b, s.curBlock)
 	}
 	s.curBlock = b
-	//s.vars = map[*Node]*ssa.Value{}
+	s.vars = map[ssaVar]*ssa.Value{}
 }
 
 // endBlock marks the end of generating code for the current block.
@@ -179,7 +187,7 @@ func (s *state) peekLine() src.XPos {
 }
 
 func (s *state) Errorf(msg string, args ...interface{}) {
-	panic(msg)
+	panic(fmt.Sprintf(msg, args...))
 }
 
 // newValue0 adds a new value with no arguments to the current block.
@@ -267,17 +275,6 @@ func (s *state) entryNewValue2(op ssa.Op, t ssa.Type, arg0, arg1 *ssa.Value) *ss
 	return s.f.Entry.NewValue2(s.peekLine(), op, t, arg0, arg1)
 }
 
-// PXOR adds a new PXOR value to the entry block.
-func (s *state) PXOR(b *ssa.Block) *ssa.Value {
-	t := Typ[types.Float32]
-	var cnst43 float32
-	cnst43 = 42.0
-
-	val0 := s.f.ConstFloat32(s.peekLine(), t, float64(cnst43))
-	val1 := s.f.ConstFloat32(s.peekLine(), t, float64(cnst43))
-	return b.NewValue2(s.peekLine(), ssa.OpAMD64PXOR, t, val0, val1)
-}
-
 // const* routines add a new const value to the entry block.
 func (s *state) constBool(c bool) *ssa.Value {
 	return s.f.ConstBool(s.peekLine(), Typ[types.Bool], c)
@@ -300,6 +297,23 @@ func (s *state) constFloat32(t ssa.Type, c float64) *ssa.Value {
 func (s *state) constFloat64(t ssa.Type, c float64) *ssa.Value {
 	return s.f.ConstFloat64(s.peekLine(), t, c)
 }
+
+// constSized returns the integer constant c of the size of its type, t.
+func (s *state) constSized(t ssa.Type, c int64) *ssa.Value {
+	switch t.Size() {
+	case 1:
+		return s.constInt8(t, int8(c))
+	case 2:
+		return s.constInt16(t, int16(c))
+	case 4:
+		return s.constInt32(t, int32(c))
+	case 8:
+		return s.constInt64(t, c)
+	default:
+		s.Fatalf("bad integer size %d", t.Size())
+		return nil
+	}
+}
 func (s *state) constInt(t ssa.Type, c int64) *ssa.Value {
 	if s.config.IntSize == 8 {
 		return s.constInt64(t, c)
@@ -325,21 +339,6 @@ func (s *state) blockLabel(block *ast.BlockStmt) *ast.LabeledStmt {
 	return nil
 }
 
-func (s *state) scanBlocksGst(fnBody gst.Stmt) {
-	var block *Block
-	b := s.f.NewBlock(ssa.BlockPlain)
-	var labelStmt *ast.LabeledStmt
-	labelStmt = &ast.LabeledStmt{}
-	var labelIdent ast.Ident
-	labelStmt.Label = &labelIdent
-	labelStmt.Stmt = &ast.ReturnStmt{}
-	block = &Block{b: b, label: labelStmt}
-	block.stmts = []ast.Stmt{labelStmt}
-	s.blocks = append(s.blocks, block)
-
-	s.checkBlocks()
-}
-
 func (s *state) scanBlocks(fnBody *ast.BlockStmt) {
 	stmtList := fnBody.List
 	entryBlock := true
@@ -349,11 +348,8 @@ func (s *state) scanBlocks(fnBody *ast.BlockStmt) {
 		var isLabel bool
 		labelStmt, isLabel = stmt.(*ast.LabeledStmt)
 		if isLabel || block == nil {
-			if block == nil {
-				if !entryBlock {
-					panic("internal error")
-				}
-				labelStmt = nil
+			if block == nil && !entryBlock {
+				panic("internal error")
 			}
 			b := s.f.NewBlock(ssa.BlockPlain)
 			block = &Block{b: b, label: labelStmt}
@@ -401,7 +397,7 @@ func (s *state) checkLastStmt(block *Block, stmt ast.Stmt) {
 	} else if ifStmt, ok := stmt.(*ast.IfStmt); ok {
 		_, _, _, err := s.matchIfStmt(ifStmt)
 		if err != nil {
-			s.Errorf(fmt.Sprintf("%v", err))
+			s.Errorf("%v", err)
 		}
 	} else if _, ok := stmt.(*ast.ReturnStmt); ok {
 		//
@@ -424,9 +420,31 @@ func (s *state) processBlocks() {
 }
 
 func (s *state) processBlock(block *Block) {
+	if !s.isEntryBlock(block) {
+		s.startBlock(block.b)
+	}
 	for _, stmt := range block.stmts {
 		s.stmt(block, stmt)
 	}
+	if !isTransfer(block.stmts[len(block.stmts)-1]) {
+		// the entry block falls through to the next block
+		if next := s.nextBlock(block); next != nil {
+			block.b.AddEdgeTo(next.b)
+		}
+	}
+	s.endBlock()
+}
+
+// isTransfer reports whether stmt transfers control out of its block.
+func isTransfer(stmt ast.Stmt) bool {
+	if lbledStmt, ok := stmt.(*ast.LabeledStmt); ok {
+		stmt = lbledStmt.Stmt
+	}
+	switch stmt.(type) {
+	case *ast.BranchStmt, *ast.IfStmt, *ast.ReturnStmt:
+		return true
+	}
+	return false
 }
 
 // body converts the body of fn to SSA and adds it to s.
@@ -493,7 +511,7 @@ func (s *state) getBlockFromName(name string) *Block {
 	return nil
 }
 
-func (s *state) matchIfStmt(stmt *ast.IfStmt) (condIdent *ast.Ident, yesLabel string, noLabel string, err error) {
+func (s *state) matchIfStmt(stmt *ast.IfStmt) (cond ast.Expr, yesLabel string, noLabel string, err error) {
 	var errored bool
 	var ok bool
 	if stmt.Init != nil {
@@ -517,8 +535,11 @@ func (s *state) matchIfStmt(stmt *ast.IfStmt) (condIdent *ast.Ident, yesLabel st
 	elseStmt, ok := elseBody.List[0].(*ast.BranchStmt)
 	errored = errored || !ok
 
-	condIdent, ok = stmt.Cond.(*ast.Ident)
-	errored = errored || !ok
+	cond = stmt.Cond
+	if _, _, isKind := gimporter.IsBlockKind(cond); !isKind && !isComparison(cond) {
+		_, ok = cond.(*ast.Ident)
+		errored = errored || !ok
+	}
 
 	if errored {
 		return nil, "", "", fmt.Errorf(errMsg)
@@ -526,10 +547,21 @@ func (s *state) matchIfStmt(stmt *ast.IfStmt) (condIdent *ast.Ident, yesLabel st
 
 	yesLabel = bodyStmt.Label.Name
 	noLabel = elseStmt.Label.Name
-	fmt.Println("if condIdent:", condIdent)
-	fmt.Println("if bdyStmt:", bodyStmt)
-	fmt.Println("if elseStmt:", elseStmt)
-	return condIdent, yesLabel, noLabel, nil
+	return cond, yesLabel, noLabel, nil
+}
+
+// isComparison reports whether expr is a comparison, like "x < y". The
+// comparison of an if is fused into its branch by the lower pass.
+func isComparison(expr ast.Expr) bool {
+	b, ok := expr.(*ast.BinaryExpr)
+	if !ok {
+		return false
+	}
+	switch b.Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return true
+	}
+	return false
 }
 
 // stmt converts the statement stmt to SSA and adds it to s.
@@ -626,32 +658,22 @@ func (s *state) stmt(block *Block, stmt ast.Stmt) {
 		//panic(fmt.Sprintf("todo ast.DeclStmt: %#v", stmt))
 	case *ast.EmptyStmt: // No op
 	case *ast.ExprStmt:
-		expr := stmt.X
-		switch expr := expr.(type) {
-		case *ast.CallExpr:
-			callexpr := expr
-			fn, ok := callexpr.Fun.(*ast.SelectorExpr)
-			if ok {
-				fnPkg := fmt.Sprintf("%v", fn.X)
-				fnName := fmt.Sprintf("%v", fn.Sel)
-				if fnPkg == "ssair" && fnName == "Op2" {
-					s.PXOR(block.b)
-				} else {
-					panic("call expr not implemented: fnPkg - " + fnPkg + ", fnName - " + fnName + ", " + fmt.Sprintf("%#v", expr))
-				}
-			} else {
-				panic("call expr not implemented")
-			}
-		default:
-			panic("default expr not implemented")
-		}
+		// the gst expression statements are "_ = expr"
+		panic(fmt.Sprintf("unexpected expression statement: %#v", stmt))
 	case *ast.IfStmt:
-		condIdent, yes, no, err := s.matchIfStmt(stmt)
+		cond, yes, no, err := s.matchIfStmt(stmt)
 		if err != nil {
 			break
 		}
-		c := s.expr(&Node{node: condIdent, ctx: s.ctx})
-		block.b.Kind = ssa.BlockIf
+		kind := ssa.BlockIf
+		if name, control, ok := gimporter.IsBlockKind(cond); ok {
+			if kind, ok = gimporter.LookupBlockKind(name); !ok {
+				s.Errorf("unknown block kind %v", name)
+			}
+			cond = control
+		}
+		c := s.expr(&Node{node: cond, ctx: s.ctx})
+		block.b.Kind = kind
 		block.b.Control = c
 		block.b.Likely = ssa.BranchUnknown
 		yesBlock := s.getBlockFromName(yes)
@@ -664,13 +686,22 @@ func (s *state) stmt(block *Block, stmt ast.Stmt) {
 		if len(stmt.Results) > 1 {
 			panic("unsupported: multiple return values")
 		}
-		if len(stmt.Results) == 1 {
+		if s.explicitMem {
+			// the ops store the result
+		} else if len(stmt.Results) == 1 {
 			res := stmt.Results[0]
 			node := NewNode(res, s.ctx)
 			t := node.Typ()
 			v := s.expr(node)
 			addr := s.retVarAddr()
 			s.vars[&memVar] = s.newValue3I(ssa.OpStore, ssa.TypeMem, t.Size(), addr, v, s.mem())
+		} else if ret := s.retVar(); ret != nil {
+			// bare return, store the current value of the result
+			retVar := s.objVar(ret.v)
+			t := retVar.Typ()
+			v := s.variable(retVar, t)
+			addr := s.retVarAddr()
+			s.vars[&memVar] = s.newValue3I(ssa.OpStore, ssa.TypeMem, t.Size(), addr, v, s.mem())
 		}
 		m := s.mem()
 		block.b.Kind = ssa.BlockRet
@@ -693,8 +724,7 @@ func (s *state) stmt(block *Block, stmt ast.Stmt) {
 	case *ast.TypeSwitchStmt:
 		panic("unsupported: TypeSwitchStmt")
 	default:
-		fmt.Println("stmt: ", stmt)
-		panic("unknown ast.Stmt")
+		panic(fmt.Sprintf("unknown ast.Stmt: %T", stmt))
 	}
 }
 
@@ -743,6 +773,10 @@ func (s *state) lookupVarIncoming(b *ssa.Block, t ssa.Type, name ssaVar) *ssa.Va
 		}
 		//return nil
 
+		if name.Class() == PAUTO {
+			// like Go, a local starts as the zero value
+			return s.zeroVal(t.(*Type))
+		}
 		if canSSA(name) {
 			v := s.entryNewValue0A(ssa.OpArg, t, name)
 			// v starts with AuxInt == 0.
@@ -768,11 +802,10 @@ func (s *state) lookupVarIncoming(b *ssa.Block, t ssa.Type, name ssaVar) *ssa.Va
 		// This block is dead; we have no predecessors and we're not the entry block.
 		// It doesn't matter what we use here as long as it is well-formed,
 		// so use the default/zero value.
-		return nil
-		// if name == &memVar {
-		// 	return s.startmem
-		// }
-		// return s.zeroVal(name.Type)
+		if name == &memVar {
+			return s.startmem
+		}
+		return s.zeroVal(t.(*Type))
 	}
 	v0 := vals[0]
 	for i := 1; i < len(vals); i++ {
@@ -789,28 +822,27 @@ func (s *state) lookupVarIncoming(b *ssa.Block, t ssa.Type, name ssaVar) *ssa.Va
 
 // lookupVarOutgoing finds the variable's value at the end of block b.
 func (s *state) lookupVarOutgoing(b *ssa.Block, t ssa.Type, name ssaVar) *ssa.Value {
-	return nil
-	// m := s.defvars[b.ID]
-	// if v, ok := m[name]; ok {
-	// 	return v
-	// }
-	// // The variable is not defined by b and we haven't
-	// // looked it up yet.  Generate v, a copy value which
-	// // will be the outgoing value of the variable.  Then
-	// // look up w, the incoming value of the variable.
-	// // Make v = copy(w).  We need the extra copy to
-	// // prevent infinite recursion when looking up the
-	// // incoming value of the variable.
-	// v := b.NewValue0(s.peekLine(), ssa.OpCopy, t)
-	// m[name] = v
-	// v.AddArg(s.lookupVarIncoming(b, t, name))
-	// return v
+	m := s.defvars[b.ID]
+	if v, ok := m[name]; ok {
+		return v
+	}
+	// The variable is not defined by b and we haven't
+	// looked it up yet.  Generate v, a copy value which
+	// will be the outgoing value of the variable.  Then
+	// look up w, the incoming value of the variable.
+	// Make v = copy(w).  We need the extra copy to
+	// prevent infinite recursion when looking up the
+	// incoming value of the variable.
+	v := b.NewValue0(s.peekLine(), ssa.OpCopy, t)
+	m[name] = v
+	v.AddArg(s.lookupVarIncoming(b, t, name))
+	return v
 }
 
 // TODO: the above mutually recursive functions can lead to very deep stacks.  Fix that.
 
-func (s *state) addNamedValue(n *Node, v *ssa.Value) {
-	if n.class == Pxxx {
+func (s *state) addNamedValue(n ssaVar, v *ssa.Value) {
+	if n.Class() == Pxxx {
 		// Don't track our dummy nodes (&memVar etc.).
 		return
 	}
@@ -820,7 +852,7 @@ func (s *state) addNamedValue(n *Node, v *ssa.Value) {
 	if v.Type == nil {
 		panic("nil v.Type (*ssa.Value)")
 	}
-	if n.class == PAUTO && (v.Type.IsString() || v.Type.IsSlice() || v.Type.IsInterface()) {
+	if n.Class() == PAUTO && (v.Type.IsString() || v.Type.IsSlice() || v.Type.IsInterface()) {
 		// TODO: can't handle auto compound objects with pointers yet.
 		return
 	}
@@ -1043,22 +1075,65 @@ var opToSSA = map[opAndType]ssa.Op{
 	opAndType{OGE, types.Float64}: ssa.OpGeq64F,
 	opAndType{OGE, types.Float32}: ssa.OpGeq32F,
 
-	// opAndType{OLROT, types.Uint8}:  ssa.OpLrot8,
-	// opAndType{OLROT, types.Uint16}: ssa.OpLrot16,
-	// opAndType{OLROT, types.Uint32}: ssa.OpLrot32,
-	// opAndType{OLROT, types.Uint64}: ssa.OpLrot64,
+	opAndType{OLROT, types.Int8}:   ssa.OpLrot8,
+	opAndType{OLROT, types.Uint8}:  ssa.OpLrot8,
+	opAndType{OLROT, types.Int16}:  ssa.OpLrot16,
+	opAndType{OLROT, types.Uint16}: ssa.OpLrot16,
+	opAndType{OLROT, types.Int32}:  ssa.OpLrot32,
+	opAndType{OLROT, types.Uint32}: ssa.OpLrot32,
+	opAndType{OLROT, types.Int64}:  ssa.OpLrot64,
+	opAndType{OLROT, types.Uint64}: ssa.OpLrot64,
 
 	opAndType{OSQRT, types.Float64}: ssa.OpSqrt,
 }
 
+// tokenOp maps go/token operators to Node ops.
+var tokenOp = map[token.Token]NodeOp{
+	token.ADD: OADD,
+	token.SUB: OSUB,
+	token.MUL: OMUL,
+	token.QUO: ODIV,
+	token.REM: OMOD,
+	token.AND: OAND,
+	token.OR:  OOR,
+	token.XOR: OXOR,
+	token.EQL: OEQ,
+	token.NEQ: ONE,
+	token.LSS: OLT,
+	token.LEQ: OLE,
+	token.GTR: OGT,
+	token.GEQ: OGE,
+}
+
+// concreteEtype returns the basic kind of t, with int, uint and uintptr
+// replaced by the sized kind of the same width.
+func (s *state) concreteEtype(t *Type) types.BasicKind {
+	basic := t.Basic()
+	if basic == nil {
+		s.Fatalf("expected basic type, got %v", t)
+	}
+	switch basic.Kind() {
+	case types.Int:
+		if s.config.IntSize == 8 {
+			return types.Int64
+		}
+		return types.Int32
+	case types.Uint, types.Uintptr:
+		if s.config.IntSize == 8 {
+			return types.Uint64
+		}
+		return types.Uint32
+	}
+	return basic.Kind()
+}
+
 func (s *state) ssaOp(op NodeOp, t *Type) ssa.Op {
-	/*etype := s.concreteEtype(t)
+	etype := s.concreteEtype(t)
 	x, ok := opToSSA[opAndType{op, etype}]
 	if !ok {
-		//s.Unimplementedf("unhandled binary op %s %s", opnames[op], Econv(int(etype), 0))
+		s.Unimplementedf("unhandled binary op %v %v", op, t)
 	}
-	return x*/
-	return opToSSA[opAndType{}]
+	return x
 }
 
 func floatForComplex(t *Type) *Type {
@@ -1209,45 +1284,253 @@ var shiftOpToSSA = map[opAndTwoTypes]ssa.Op{
 	opAndTwoTypes{ORSH, types.Uint64, types.Uint64}: ssa.OpRsh64Ux64,
 }
 
+// ssaShiftOp returns the op shifting a value of type t by a count of
+// type u. gimporter.Check allows only constant signed counts, they
+// shift as the unsigned count of their size.
 func (s *state) ssaShiftOp(op NodeOp, t *Type, u *Type) ssa.Op {
-	return opToSSA[opAndType{}]
-	/*etype1 := s.concreteEtype(t)
-	etype2 := s.concreteEtype(u)
+	etype1 := s.concreteEtype(t)
+	etype2 := unsignedKind(s.concreteEtype(u))
 	x, ok := shiftOpToSSA[opAndTwoTypes{op, etype1, etype2}]
 	if !ok {
-		//s.Unimplementedf("unhandled shift op %s etype=%s/%s", opnames[op], Econv(int(etype1), 0), Econv(int(etype2), 0))
+		s.Unimplementedf("unhandled shift op %v etype=%v/%v", op, t, u)
 	}
-	return x*/
+	return x
 }
 
+// ssaRotateOp returns the op rotating a value of type t left by a
+// constant count.
 func (s *state) ssaRotateOp(op NodeOp, t *Type) ssa.Op {
-	return opToSSA[opAndType{}]
-	/*etype1 := s.concreteEtype(t)
+	etype1 := s.concreteEtype(t)
 	x, ok := opToSSA[opAndType{op, etype1}]
 	if !ok {
-		//s.Unimplementedf("unhandled rotate op %s etype=%s", opnames[op], Econv(int(etype1), 0))
+		s.Unimplementedf("unhandled rotate op %v etype=%v", op, t)
+	}
+	return x
+}
+
+// unsignedKind returns the unsigned kind of the same size as the
+// integer kind k.
+func unsignedKind(k types.BasicKind) types.BasicKind {
+	switch k {
+	case types.Int8:
+		return types.Uint8
+	case types.Int16:
+		return types.Uint16
+	case types.Int32:
+		return types.Uint32
+	case types.Int64:
+		return types.Uint64
+	}
+	return k
+}
+
+// shift returns the value x, of type t, shifted by the count, of the
+// expression y, with Go's semantics for counts of the size of t or
+// more. A constant count is a uint64.
+func (s *state) shift(op NodeOp, t *Type, x *ssa.Value, y ast.Expr) *ssa.Value {
+	if tv := s.ctx.fn.Types[y]; tv.Value != nil {
+		c, _ := constant.Uint64Val(tv.Value)
+		u := Typ[types.Uint64]
+		return s.newValue2(s.ssaShiftOp(op, t, u), t, x, s.constInt64(u, int64(c)))
+	}
+	n := ExprNode(y, s.ctx)
+	return s.newValue2(s.ssaShiftOp(op, t, n.Typ().(*Type)), t, x, s.expr(n))
+}
+
+// rotate returns the value of the rotate builtin, call, of type t. A
+// constant count is a single rotate, otherwise it's the shifts
+// x<<(k&(w-1)) | x>>((w-k)&(w-1)) for a left rotate of the w bits of x.
+func (s *state) rotate(call *ast.CallExpr, left bool, t *Type) *ssa.Value {
+	x := s.expr(ExprNode(call.Args[0], s.ctx))
+	w := t.Size() * 8
+	if tv := s.ctx.fn.Types[call.Args[1]]; tv.Value != nil {
+		k, _ := constant.Int64Val(tv.Value)
+		k &= w - 1
+		if !left {
+			k = (w - k) & (w - 1)
+		}
+		if k == 0 {
+			return x
+		}
+		return s.newValue1I(s.ssaRotateOp(OLROT, t), t, k, x)
+	}
+	n := ExprNode(call.Args[1], s.ctx)
+	u := n.Typ().(*Type)
+	k := s.expr(n)
+	mask := s.constSized(u, w-1)
+	l := s.newValue2(s.ssaOp(OAND, u), u, k, mask)
+	r := s.newValue2(s.ssaOp(OAND, u), u, s.newValue2(s.ssaOp(OSUB, u), u, s.constSized(u, w), k), mask)
+	if !left {
+		l, r = r, l
+	}
+	// the right shift is unsigned for signed x too
+	ut := Typ[unsignedKind(s.concreteEtype(t))]
+	lsh := s.newValue2(s.ssaShiftOp(OLSH, t, u), t, x, l)
+	rsh := s.newValue2(s.ssaShiftOp(ORSH, ut, u), t, x, r)
+	return s.newValue2(s.ssaOp(OOR, t), t, lsh, rsh)
+}
+
+// conversion returns the value of the conversion, call, to the type t.
+// Integers are truncated or extended and ints and floats converted by
+// the ops of fpConvOpToSSA, as the Go compiler does.
+func (s *state) conversion(call *ast.CallExpr, t *Type) *ssa.Value {
+	n := ExprNode(call.Args[0], s.ctx)
+	ft := n.Typ().(*Type)
+	x := s.expr(n)
+	if ft.IsInteger() && t.IsInteger() {
+		var op ssa.Op
+		if t.Size() == ft.Size() {
+			op = ssa.OpCopy
+		} else if t.Size() < ft.Size() {
+			// truncation
+			switch 10*ft.Size() + t.Size() {
+			case 21:
+				op = ssa.OpTrunc16to8
+			case 41:
+				op = ssa.OpTrunc32to8
+			case 42:
+				op = ssa.OpTrunc32to16
+			case 81:
+				op = ssa.OpTrunc64to8
+			case 82:
+				op = ssa.OpTrunc64to16
+			case 84:
+				op = ssa.OpTrunc64to32
+			default:
+				s.Fatalf("weird integer truncation %v -> %v", ft, t)
+			}
+		} else if ft.IsSigned() {
+			// sign extension
+			switch 10*ft.Size() + t.Size() {
+			case 12:
+				op = ssa.OpSignExt8to16
+			case 14:
+				op = ssa.OpSignExt8to32
+			case 18:
+				op = ssa.OpSignExt8to64
+			case 24:
+				op = ssa.OpSignExt16to32
+			case 28:
+				op = ssa.OpSignExt16to64
+			case 48:
+				op = ssa.OpSignExt32to64
+			default:
+				s.Fatalf("bad integer sign extension %v -> %v", ft, t)
+			}
+		} else {
+			// zero extension
+			switch 10*ft.Size() + t.Size() {
+			case 12:
+				op = ssa.OpZeroExt8to16
+			case 14:
+				op = ssa.OpZeroExt8to32
+			case 18:
+				op = ssa.OpZeroExt8to64
+			case 24:
+				op = ssa.OpZeroExt16to32
+			case 28:
+				op = ssa.OpZeroExt16to64
+			case 48:
+				op = ssa.OpZeroExt32to64
+			default:
+				s.Fatalf("weird integer zero extension %v -> %v", ft, t)
+			}
+		}
+		return s.newValue1(op, t, x)
+	}
+	conv, ok := fpConvOpToSSA[twoTypes{s.concreteEtype(ft), s.concreteEtype(t)}]
+	if !ok {
+		s.Unimplementedf("unhandled conversion %v -> %v", ft, t)
+	}
+	op1, op2, it := conv.op1, conv.op2, conv.intermediateType
+	if op1 != ssa.OpInvalid && op2 != ssa.OpInvalid {
+		// normal case, not tripping over unsigned 64
+		if op1 == ssa.OpCopy {
+			if op2 == ssa.OpCopy {
+				return x
+			}
+			return s.newValue1(op2, t, x)
+		}
+		if op2 == ssa.OpCopy {
+			return s.newValue1(op1, t, x)
+		}
+		return s.newValue1(op2, t, s.newValue1(op1, Typ[it], x))
+	}
+	if ft.IsInteger() {
+		return s.uint64ToFloat(x, t)
 	}
-	return x*/
+	return s.floatToUint64(x, ft, t)
+}
+
+// uint64ToFloat returns the uint64, x, converted to the float type t.
+// The Go compiler branches on the top bit of x, here x is halved,
+// rounding to odd, if the bit is set and the result doubled without a
+// branch: h = x >> 63, float(x>>h | x&h) * float(h+1).
+func (s *state) uint64ToFloat(x *ssa.Value, t *Type) *ssa.Value {
+	u := Typ[types.Uint64]
+	cvt, mul := ssa.OpCvt64to64F, ssa.OpMul64F
+	if t.Size() == 4 {
+		cvt, mul = ssa.OpCvt64to32F, ssa.OpMul32F
+	}
+	h := s.newValue2(ssa.OpRsh64Ux64, u, x, s.constInt64(u, 63))
+	z := s.newValue2(ssa.OpRsh64Ux64, u, x, h)
+	z = s.newValue2(ssa.OpOr64, u, z, s.newValue2(ssa.OpAnd64, u, x, h))
+	two := s.newValue2(ssa.OpAdd64, u, h, s.constInt64(u, 1))
+	return s.newValue2(mul, t, s.newValue1(cvt, t, z), s.newValue1(cvt, t, two))
+}
+
+// floatToUint64 returns the float, x, of type ft converted to the uint64
+// type t. A float of 2^63 or more is x - 2^63 converted with the top bit
+// set. The conversion of x itself is then the min int64, its sign picks
+// the result without a branch.
+func (s *state) floatToUint64(x *ssa.Value, ft, t *Type) *ssa.Value {
+	cvt, sub := ssa.OpCvt64Fto64, ssa.OpSub64F
+	big := s.constFloat64(ft, 1<<63)
+	if ft.Size() == 4 {
+		cvt, sub = ssa.OpCvt32Fto64, ssa.OpSub32F
+		big = s.constFloat32(ft, 1<<63)
+	}
+	a := s.newValue1(cvt, t, x)
+	b := s.newValue1(cvt, t, s.newValue2(sub, ft, x, big))
+	b = s.newValue2(ssa.OpXor64, t, b, s.constInt64(t, -1<<63))
+	m := s.newValue2(ssa.OpRsh64x64, t, a, s.constInt64(Typ[types.Uint64], 63))
+	// a&^m | b&m
+	a = s.newValue2(ssa.OpAnd64, t, a, s.newValue1(ssa.OpCom64, t, m))
+	b = s.newValue2(ssa.OpAnd64, t, b, m)
+	return s.newValue2(ssa.OpOr64, t, a, b)
 }
 
+// ssaVar returns the variable for the identifier n.
 func (s *state) ssaVar(n *Node) ssaVar {
-	// fn := s.ctx.fn
-	// scope := fn.Scopes[n.node]
-	// typeObject := scope.Lookup(n.Name())
-	// if typeObject == nil {
-	// 	panic("couldn't lookup node in scope")
-	// }
-	// typeObject.
-	// fn.Defs
+	ident, ok := n.node.(*ast.Ident)
+	if !ok {
+		panic(fmt.Sprintf("expected ident, got %#v", n.node))
+	}
+	obj := s.fnInfo.ObjectOf(ident)
+	if obj == nil {
+		panic("couldn't find var for node n")
+	}
+	return s.objVar(obj)
+}
 
-	vars := getVars(s.ctx, s.fnDecl, s.fnType)
-	for _, v := range vars {
-		if v.Name() == n.Name() {
-			return v
+// objVar returns the variable for the parameter, result or local, obj.
+func (s *state) objVar(obj types.Object) ssaVar {
+	if v, ok := s.objVars[obj]; ok {
+		return v
+	}
+	var v ssaVar = &ssaLocal{obj: obj, ctx: s.ctx}
+	for _, p := range getParameters(s.ctx, s.fnType) {
+		if p.v == obj {
+			v = p
+		}
+	}
+	for _, r := range getReturnVar(s.ctx, s.fnType) {
+		if r.v == obj {
+			v = r
 		}
 	}
-	fmt.Printf("n: %#v", n)
-	panic("couldn't find var for node n")
+	s.objVars[obj] = v
+	return v
 }
 
 // expr converts the expression n to ssa, adds it to s and returns the ssa result.
@@ -1257,6 +1540,13 @@ func (s *state) expr(n *Node) *ssa.Value {
 	//s.stmtList(n.Ninit)
 	ctx := s.ctx
 
+	// constant expressions are folded by the type checker
+	if expr, ok := n.node.(ast.Expr); ok {
+		if tv := ctx.fn.Types[expr]; tv.Value != nil {
+			return s.constVal(n, tv.Value)
+		}
+	}
+
 	switch expr := n.node.(type) {
 	case *ast.Ident:
 		if canSSA(n) {
@@ -1269,100 +1559,110 @@ func (s *state) expr(n *Node) *ssa.Value {
 	case *ast.BasicLit:
 		typeAndValue := ctx.fn.Types[expr]
 		// t := typeAndValue.Type
-		v := typeAndValue.Value
-
-		switch v.Kind() {
-		case constant.Int:
-			i, ok := constant.Int64Val(v)
-			if !ok {
-				panic("internal error")
-			}
-			switch n.Typ().Size() {
-			case 1:
-				return s.constInt8(n.Typ(), int8(i))
-			case 2:
-				return s.constInt16(n.Typ(), int16(i))
-			case 4:
-				return s.constInt32(n.Typ(), int32(i))
-			case 8:
-				return s.constInt64(n.Typ(), i)
-			default:
-				s.Fatalf("bad integer size %d", n.Typ().Size())
-				return nil
-			}
-		case constant.String:
-			return s.entryNewValue0A(ssa.OpConstString, n.Typ(), constant.StringVal(v))
-		case constant.Bool:
-			return s.constBool(constant.BoolVal(v))
-		case constant.Unknown:
-			panic("unknown basic literal")
-
-		case constant.Float:
-			f, ok := constant.Float64Val(v)
-			if !ok {
-				panic("internal error")
-			}
-
-			switch n.Typ().Size() {
-			case 4:
-				// -0.0 literals need to be treated as if they were 0.0, adding 0.0 here
-				// accomplishes this while not affecting other values.
-				return s.constFloat32(n.Typ(), float64(float32(f)+0.0))
-			case 8:
-				return s.constFloat64(n.Typ(), f+0.0)
-			default:
-				s.Fatalf("bad float size %d", n.Typ().Size())
-				return nil
-			}
-		case constant.Complex:
-			panic("complex numbers not supported")
-		default:
-			s.Unimplementedf("unhandled literal %#v", expr)
-			return nil
-		}
-	case *ast.BinaryExpr:
-		// TODO
+		return s.constVal(n, typeAndValue.Value)
+	case *ast.UnaryExpr:
+		t := n.Typ().(*Type)
+		x := s.expr(ExprNode(expr.X, s.ctx))
 		switch expr.Op {
 		case token.ADD:
-			return s.entryNewValue2(ssa.OpAdd64, n.Typ(), s.expr(ExprNode(expr.X, s.ctx)), s.expr(ExprNode(expr.Y, s.ctx)))
+			return x
 		case token.SUB:
-			//
-		case token.MUL:
-			//
-		case token.QUO:
-			//
-		case token.REM:
-			//
-		case token.AND:
-			//
-		case token.OR:
-			//
+			return s.newValue1(s.ssaOp(OMINUS, t), t, x)
 		case token.XOR:
-			//
-		case token.SHL:
-			//
-		case token.SHR:
-			//
+			return s.newValue1(s.ssaOp(OCOM, t), t, x)
+		case token.NOT:
+			return s.newValue1(s.ssaOp(ONOT, t), t, x)
+		}
+		panic("unimplementedf *ast.UnaryExpr")
+	case *ast.BinaryExpr:
+		switch expr.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO, token.REM,
+			token.AND, token.OR, token.XOR:
+			t := n.Typ().(*Type)
+			a := s.expr(ExprNode(expr.X, s.ctx))
+			b := s.expr(ExprNode(expr.Y, s.ctx))
+			return s.newValue2(s.ssaOp(tokenOp[expr.Op], t), t, a, b)
 		case token.AND_NOT:
-			//
+			// x &^ y is x & ^y, as the Go compiler walks it
+			t := n.Typ().(*Type)
+			a := s.expr(ExprNode(expr.X, s.ctx))
+			b := s.expr(ExprNode(expr.Y, s.ctx))
+			c := s.newValue1(s.ssaOp(OCOM, t), t, b)
+			return s.newValue2(s.ssaOp(OAND, t), t, a, c)
+		case token.SHL, token.SHR:
+			op := OLSH
+			if expr.Op == token.SHR {
+				op = ORSH
+			}
+			t := n.Typ().(*Type)
+			return s.shift(op, t, s.expr(ExprNode(expr.X, s.ctx)), expr.Y)
 		case token.LAND:
 			//
-		case token.LEQ:
-			//
 		case token.LOR:
 			//
-		case token.LSS:
-			//
-		case token.GTR:
-			//
-
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			x := ExprNode(expr.X, s.ctx)
+			a := s.expr(x)
+			b := s.expr(ExprNode(expr.Y, s.ctx))
+			return s.newValue2(s.ssaOp(tokenOp[expr.Op], x.Typ().(*Type)), Typ[types.Bool], a, b)
 		}
 		panic("unimplementedf *ast.BinaryExpr")
+	case *ast.CallExpr:
+		if call, ok := gimporter.IsConversion(expr); ok {
+			return s.conversion(call, n.Typ().(*Type))
+		}
+		if call, ok := gimporter.IsSqrt(expr); ok {
+			t := n.Typ().(*Type)
+			x := s.expr(ExprNode(call.Args[0], s.ctx))
+			return s.newValue1(s.ssaOp(OSQRT, t), t, x)
+		}
+		panic(fmt.Sprintf("unimplemented call: %#v", expr))
 	default:
 		panic(fmt.Sprintf("unimplemented expr: %#v", expr))
 	}
 }
 
+// constVal returns the ssa value of the constant v, the value of the expression n.
+func (s *state) constVal(n *Node, v constant.Value) *ssa.Value {
+	switch v.Kind() {
+	case constant.Int:
+		i, ok := constant.Int64Val(v)
+		if !ok {
+			panic("internal error")
+		}
+		return s.constSized(n.Typ(), i)
+	case constant.String:
+		return s.entryNewValue0A(ssa.OpConstString, n.Typ(), constant.StringVal(v))
+	case constant.Bool:
+		return s.constBool(constant.BoolVal(v))
+	case constant.Unknown:
+		panic("unknown constant")
+
+	case constant.Float:
+		f, ok := constant.Float64Val(v)
+		if !ok {
+			panic("internal error")
+		}
+
+		switch n.Typ().Size() {
+		case 4:
+			// -0.0 literals need to be treated as if they were 0.0, adding 0.0 here
+			// accomplishes this while not affecting other values.
+			return s.constFloat32(n.Typ(), float64(float32(f)+0.0))
+		case 8:
+			return s.constFloat64(n.Typ(), f+0.0)
+		default:
+			s.Fatalf("bad float size %d", n.Typ().Size())
+			return nil
+		}
+	case constant.Complex:
+		panic("complex numbers not supported")
+	default:
+		s.Unimplementedf("unhandled constant %v", v)
+		return nil
+	}
+}
+
 // condBranch evaluates the boolean expression cond and branches to yes
 // if cond is true and no if cond is false.
 // This function is intended to handle && and || better than just calling
@@ -1414,6 +1714,9 @@ func (s *state) assignStmt(stmt *ast.AssignStmt) {
 	if len(stmt.Lhs) == 0 || len(stmt.Rhs) == 0 {
 		panic("internal error")
 	}
+	if stmt.Tok != token.DEFINE && stmt.Tok != token.ASSIGN {
+		panic("internal error")
+	}
 	leftExpr := stmt.Lhs[0]
 	rightExpr := stmt.Rhs[0]
 	leftIdent, ok := leftExpr.(*ast.Ident)
@@ -1421,37 +1724,31 @@ func (s *state) assignStmt(stmt *ast.AssignStmt) {
 		s.Errorf("expected ident")
 		return
 	}
-	rightValue := s.expr(&Node{node: rightExpr, ctx: s.ctx, class: PAUTO})
-	if stmt.Tok == token.DEFINE {
-		leftNode := &Node{node: leftIdent, ctx: s.ctx, class: PAUTO}
-		leftObj := s.fnInfo.ObjectOf(leftIdent)
-		leftVar := &ssaLocal{obj: leftObj, ctx: s.ctx}
-		s.addNamedValue(leftNode, rightValue)
-		if canSSA(leftNode) {
-			// Update variable assignment.
-			s.vars[leftVar] = rightValue
-			s.addNamedValue(leftNode, rightValue)
-			return
-		} else {
-			panic("can't ssa node")
-		}
-	} else if stmt.Tok == token.ASSIGN {
-		leftNode := &Node{node: leftIdent, ctx: s.ctx, class: PAUTO}
-		leftObj := s.fnInfo.ObjectOf(leftIdent)
-		leftVar := &ssaLocal{obj: leftObj, ctx: s.ctx}
-		s.addNamedValue(leftNode, rightValue)
-		if canSSA(leftNode) {
-			// Update variable assignment.
-			s.vars[leftVar] = rightValue
-			s.addNamedValue(leftNode, rightValue)
-			return
-		} else {
-			panic("can't ssa node")
-		}
+	var rightValue *ssa.Value
+	if call, ok := gimporter.IsPhi(rightExpr); ok {
+		rightValue = s.phi(call, ExprNode(leftIdent, s.ctx).Typ())
+	} else if op, ok := gimporter.IsOp(rightExpr); ok {
+		rightValue = s.op(op)
+	} else if call, left, ok := gimporter.IsRotate(rightExpr); ok {
+		rightValue = s.rotate(call, left, ExprNode(leftIdent, s.ctx).Typ().(*Type))
 	} else {
-		panic("internal error")
+		rightValue = s.expr(&Node{node: rightExpr, ctx: s.ctx, class: PAUTO})
 	}
-
+	if rightValue.Type.IsMemory() {
+		// a phi or op of type mem is the memory after it
+		s.vars[&memVar] = rightValue
+	}
+	if isBlankIdent(leftIdent) {
+		return
+	}
+	leftNode := &Node{node: leftIdent, ctx: s.ctx, class: PAUTO}
+	leftNode.Var = s.ssaVar(leftNode)
+	if !canSSA(leftNode) {
+		panic("can't ssa node")
+	}
+	// Update variable assignment.
+	s.vars[leftNode.Var] = rightValue
+	s.addNamedValue(leftNode.Var, rightValue)
 }
 
 func canSSA(n ssaVar) bool {
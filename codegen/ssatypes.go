@@ -2,10 +2,13 @@ package codegen
 
 import (
 	"fmt"
+	"go/token"
 	"go/types"
+	"strings"
 
 	"github.com/bjwbell/cmd/obj"
 	"github.com/bjwbell/cmd/src"
+	"github.com/bjwbell/gir/dump"
 	"github.com/bjwbell/ssa"
 )
 
@@ -31,6 +34,14 @@ func (l *ssaLabel) used() bool { return l.useNode != nil }
 // ssaExport exports a bunch of compiler services for the ssa backend.
 type ssaExport struct {
 	log bool
+	// passes are the dumps of the function after each pass of
+	// ssa.Compile and pass is the pass whose dump is logged next
+	passes map[string]*dump.Func
+	pass   string
+	// autos is the number of spill slots made by Auto
+	autos int
+	// frameSize is the size of the frame, set by AllocFrame
+	frameSize int64
 }
 
 func (s *ssaExport) TypeBool() ssa.Type    { return Typ[types.Bool] }
@@ -56,30 +67,53 @@ func (*ssaExport) StringData(s string) interface{} {
 	return nil
 }
 
+// Auto returns a new local of type t for the register allocator to
+// spill values to, AllocFrame assigns its place in the frame.
 func (e *ssaExport) Auto(t ssa.Type) ssa.GCNode {
-	/*n := temp(t.(*Type))   // Note: adds new auto to Curfn.Func.Dcl list
-	e.mustImplement = true // This modifies the input to SSA, so we want to make sure we succeed from here!*/
-	//return n
-	return nil
+	typ, ok := t.(*Type)
+	if !ok {
+		Fatalf("can't spill values of type %v", t)
+	}
+	name := fmt.Sprintf("autotmp_%d", e.autos)
+	e.autos++
+	return &ssaLocal{obj: types.NewVar(token.NoPos, nil, name, typ.Type)}
 }
 
 func (e *ssaExport) CanSSA(t ssa.Type) bool {
 	return true //canSSAType(t.(*Type))
 }
 
-// Log logs a message from the compiler.
+// Log logs a message from the compiler. After each pass ssa.Compile
+// logs "  pass %s end %s\n" and then the function, which is kept.
 func (e *ssaExport) Logf(msg string, args ...interface{}) {
+	if strings.HasPrefix(msg, "  pass %s end") && len(args) > 0 {
+		e.pass, _ = args[0].(string)
+	} else if f, ok := logFunc(msg, args); ok && e.pass != "" {
+		if e.passes == nil {
+			e.passes = map[string]*dump.Func{}
+		}
+		e.passes[e.pass] = Dump(f)
+		e.pass = ""
+	}
 	// If e was marked as unimplemented, anything could happen. Ignore.
 	if e.log {
 		fmt.Printf(msg, args...)
 	}
 }
 
+// logFunc returns the function logged by Logf, "%s" and the function.
+func logFunc(msg string, args []interface{}) (*ssa.Func, bool) {
+	if msg != "%s" || len(args) != 1 {
+		return nil, false
+	}
+	f, ok := args[0].(*ssa.Func)
+	return f, ok
+}
+
+// Fatalf panics with the internal compiler error, it's recovered as an
+// error by Build and compile.Compile.
 func Fatalf(format string, args ...interface{}) {
-	msg := "internal compiler error: " + format
-	fmt.Printf(msg, args)
-	fmt.Printf("\n")
-	panic("")
+	panic(fmt.Sprintf("internal compiler error: "+format, args...))
 }
 
 // Fatal reports a compiler error and exits.
@@ -157,9 +191,40 @@ func (e *ssaExport) SplitArray(localSlot ssa.LocalSlot) ssa.LocalSlot {
 	return ssa.LocalSlot{}
 }
 
-// AllocFrame assigns frame offsets to all live auto variables.
+// AllocFrame assigns frame offsets to all live auto variables, the
+// spill slots of the register allocator and the locals with their
+// address taken. They're laid out from SP in the order they're used,
+// each aligned to its type.
 func (e *ssaExport) AllocFrame(f *ssa.Func) {
-	// TODO
+	var autos []*ssaLocal
+	seen := map[*ssaLocal]bool{}
+	use := func(n interface{}) {
+		if local, ok := n.(*ssaLocal); ok && !seen[local] {
+			seen[local] = true
+			autos = append(autos, local)
+		}
+	}
+	for _, b := range f.Blocks {
+		for _, v := range b.Values {
+			if sym, ok := v.Aux.(*ssa.AutoSymbol); ok {
+				use(sym.Node)
+			}
+			if int(v.ID) < len(f.RegAlloc) {
+				if slot, ok := f.RegAlloc[v.ID].(ssa.LocalSlot); ok {
+					use(slot.N)
+				}
+			}
+		}
+	}
+	sizes := StdSizes()
+	var off int64
+	for _, local := range autos {
+		t := local.obj.Type()
+		off = align(off, sizes.Alignof(t))
+		local.offset = off
+		off += sizes.Sizeof(t)
+	}
+	e.frameSize = align(off, sizes.WordSize)
 }
 
 // Syslook returns a symbol of the runtime function/variable with the
@@ -3,6 +3,8 @@ package codegen
 import (
 	"fmt"
 	"go/types"
+	"strconv"
+	"strings"
 
 	"github.com/bjwbell/ssa"
 )
@@ -20,12 +22,26 @@ type ssaParam struct {
 	ssaVar
 	v   *types.Var
 	ctx Ctx
+	// sig is the signature of the function, v is its index'th param
+	sig   *types.Signature
+	index int
 }
 
 func (p *ssaParam) Name() string {
+	if p.v.Name() == "" && p.sig != nil {
+		return p.asmName()
+	}
 	return p.v.Name()
 }
 
+// asmName returns the name of the param in the assembly, "x+0(FP)".
+func (p *ssaParam) asmName() string {
+	if p.sig == nil {
+		return p.v.Name()
+	}
+	return argName(p.sig.Params(), p.index, "arg")
+}
+
 func (p ssaParam) String() string {
 	return fmt.Sprintf("{ssaParam: %v}", p.Name())
 }
@@ -35,8 +51,11 @@ func (p *ssaParam) Class() NodeClass {
 }
 
 func (p *ssaParam) Xoffset() int64 {
-	// TODO
-	return 0
+	if p.sig == nil {
+		return 0
+	}
+	params, _, _ := ArgOffsets(p.sig)
+	return params[p.index]
 }
 
 func (p ssaParam) Typ() ssa.Type {
@@ -47,15 +66,24 @@ type ssaRetVar struct {
 	ssaVar
 	v   *types.Var
 	ctx Ctx
+	// sig is the signature of the function, v is its index'th result
+	sig   *types.Signature
+	index int
 }
 
 func (p *ssaRetVar) Name() string {
-	name := p.v.Name()
-	if name == "" {
-		name = "ret0"
+	if p.v.Name() == "" {
+		return p.asmName()
+	}
+	return p.v.Name()
+}
+
+// asmName returns the name of the result in the assembly, "ret+16(FP)".
+func (p *ssaRetVar) asmName() string {
+	if p.sig == nil {
+		return "ret"
 	}
-	fmt.Println("SSARETVAR.NAME(): ", name)
-	return name
+	return argName(p.sig.Results(), p.index, "ret")
 }
 
 func (p ssaRetVar) String() string {
@@ -68,8 +96,11 @@ func (p *ssaRetVar) Class() NodeClass {
 }
 
 func (p *ssaRetVar) Xoffset() int64 {
-	// TODO
-	return 8
+	if p.sig == nil {
+		return 0
+	}
+	_, results, _ := ArgOffsets(p.sig)
+	return results[p.index]
 }
 
 func (p ssaRetVar) Typ() ssa.Type {
@@ -80,6 +111,8 @@ type ssaLocal struct {
 	ssaVar
 	obj types.Object
 	ctx Ctx
+	// offset is the offset of the local from SP, set by AllocFrame
+	offset int64
 }
 
 func (local *ssaLocal) Name() string {
@@ -95,10 +128,33 @@ func (local *ssaLocal) Class() NodeClass {
 }
 
 func (local *ssaLocal) Xoffset() int64 {
-	// TODO
-	return 0
+	return local.offset
 }
 
 func (local ssaLocal) Typ() ssa.Type {
-	return &Type{local.obj.Type()}
+	return ssaType(local.obj.Type())
+}
+
+// argName returns the name of the index'th param or result of tuple in
+// the assembly, as go vet names them. Unnamed params are arg, arg1, ...
+// and unnamed results are ret, ret1, ...
+func argName(tuple *types.Tuple, index int, unnamed string) string {
+	if name := tuple.At(index).Name(); name != "" && name != "_" && !strings.HasPrefix(name, "~") {
+		return name
+	}
+	if index > 0 {
+		unnamed += strconv.Itoa(index)
+	}
+	return unnamed
+}
+
+// asmName returns the name of the param or result, n, in the assembly.
+func asmName(n ssaVar) string {
+	switch n := n.(type) {
+	case *ssaParam:
+		return n.asmName()
+	case *ssaRetVar:
+		return n.asmName()
+	}
+	return n.Name()
 }
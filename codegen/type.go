@@ -3,6 +3,7 @@ package codegen
 import (
 	"go/types"
 
+	"github.com/bjwbell/gir/gimporter"
 	"github.com/bjwbell/ssa"
 )
 
@@ -18,6 +19,43 @@ func StdSizes() types.StdSizes {
 	return std
 }
 
+// align rounds off up to a multiple of a.
+func align(off, a int64) int64 {
+	return (off + a - 1) / a * a
+}
+
+// ArgOffsets returns the offsets from FP of the params and results of
+// the signature, sig, and the size of the args. As in Go, the results
+// start at a word boundary after the params and, as go vet expects, the
+// size is the end of the last one, not rounded up.
+func ArgOffsets(sig *types.Signature) (params, results []int64, size int64) {
+	sizes := StdSizes()
+	var off int64
+	fields := func(tuple *types.Tuple) []int64 {
+		var offsets []int64
+		for i := 0; i < tuple.Len(); i++ {
+			t := tuple.At(i).Type()
+			off = align(off, sizes.Alignof(t))
+			offsets = append(offsets, off)
+			off += sizes.Sizeof(t)
+		}
+		return offsets
+	}
+	params = fields(sig.Params())
+	if sig.Results().Len() > 0 {
+		off = align(off, sizes.WordSize)
+	}
+	results = fields(sig.Results())
+	return params, results, off
+}
+
+// ArgsSize returns the size of the params and results of the signature,
+// sig, the argsize of its TEXT directive.
+func ArgsSize(sig *types.Signature) int64 {
+	_, _, size := ArgOffsets(sig)
+	return size
+}
+
 var Typ = []*Type{
 	types.Bool:          &Type{types.Typ[types.Bool]},
 	types.Int:           &Type{types.Typ[types.Int]},
@@ -48,6 +86,18 @@ var Typ = []*Type{
 	// types.UntypedNil:     CTNIL
 }
 
+// ssaType returns the ssa.Type of t, the GIR types mem and flags are
+// ssa.TypeMem and ssa.TypeFlags.
+func ssaType(t types.Type) ssa.Type {
+	switch t {
+	case gimporter.Mem:
+		return ssa.TypeMem
+	case gimporter.Flags:
+		return ssa.TypeFlags
+	}
+	return &Type{t}
+}
+
 // Basic returns *types.Basic if t.Type is *types.Basic
 // else nil is returned.
 func (t *Type) Basic() *types.Basic {
@@ -0,0 +1,209 @@
+// Package compile compiles GIR source, or a GOSSAFUNC SSA dump, to Go
+// assembly and the Go prototypes of its functions. It's the gir command
+// as a library.
+package compile // import "github.com/bjwbell/gir/compile"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/bjwbell/gir/codegen"
+	"github.com/bjwbell/gir/config"
+	"github.com/bjwbell/gir/ctx"
+	"github.com/bjwbell/gir/dump"
+	"github.com/bjwbell/gir/parse"
+	"github.com/bjwbell/gir/scan"
+	"github.com/bjwbell/gir/token"
+	"github.com/bjwbell/ssa"
+)
+
+// Options are the options of Compile. The zero value compiles GIR source.
+type Options struct {
+	// SSA is whether the source is a GOSSAFUNC SSA dump instead of GIR.
+	SSA bool
+	// Pkg is the package of the prototypes of an SSA dump, "main" if
+	// it's empty. GIR source declares its package.
+	Pkg string
+	// Gir is whether to print the compiled functions as GIR, Func.Gir.
+	Gir bool
+	// Pass is the pass of ssa.Compile, like "lower", after which the
+	// functions are printed as GIR, after the last pass if it's empty.
+	Pass string
+	// Log is whether the SSA backend logs its passes to stdout.
+	Log bool
+}
+
+// Result is the output of Compile.
+type Result struct {
+	// Pkg is the package of the functions.
+	Pkg string
+	// Funcs are the functions compiled without errors, in source order.
+	Funcs []*Func
+	// Diagnostics are the errors of the source, with their positions.
+	Diagnostics scan.ErrorList
+}
+
+// Func is a compiled function.
+type Func struct {
+	Name string
+	// SSA is the compiled SSA form of the function.
+	SSA *ssa.Func
+	// Asm is the Go assembly of the function.
+	Asm string
+	// Proto is the Go prototype of the function.
+	Proto string
+	// Gir is the compiled function as GIR if Options.Gir is set.
+	Gir string
+}
+
+// Compile compiles the source, src, of the file, name. After an error
+// it continues with the next function; the returned error is the
+// scan.ErrorList of the diagnostics and the result has the functions
+// compiled without errors. The panics of the parser, the ssa package
+// and the code generator are diagnostics and don't escape.
+func Compile(name string, src io.Reader, opts Options) (res *Result, err error) {
+	res = &Result{Pkg: opts.Pkg}
+	if res.Pkg == "" {
+		res.Pkg = "main"
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			res.Diagnostics.Add(token.Pos{File: name}, fmt.Sprint(r))
+		}
+		err = res.Diagnostics.Err()
+	}()
+	if opts.SSA {
+		compileDump(name, src, opts, res)
+	} else {
+		compileGir(name, src, opts, res)
+	}
+	return res, nil
+}
+
+// Asm returns the assembly file of the functions, the preamble and then
+// the TEXT block of each function.
+func (res *Result) Asm() string {
+	var buf bytes.Buffer
+	buf.WriteString(codegen.Preamble())
+	for i, fn := range res.Funcs {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(fn.Asm)
+	}
+	return buf.String()
+}
+
+// Proto returns the Go file of the prototypes of the functions, in the
+// order of their TEXT blocks in Asm.
+func (res *Result) Proto() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// +build amd64\n\npackage %s\n", res.Pkg)
+	for _, fn := range res.Funcs {
+		buf.WriteString(fn.Proto)
+	}
+	return buf.String()
+}
+
+// Gir returns the GIR file of the functions if Options.Gir is set.
+func (res *Result) Gir() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n", res.Pkg)
+	for _, fn := range res.Funcs {
+		buf.WriteString("\n" + fn.Gir)
+	}
+	return buf.String()
+}
+
+// compileGir compiles the functions of the GIR source, src.
+func compileGir(name string, src io.Reader, opts Options, res *Result) {
+	var conf config.Config
+	context := ctx.NewContext(&conf)
+	scanner := scan.New(context, name, bufio.NewReader(src))
+	file, err := parse.NewParser(name, scanner, context).ParseFile()
+	if list, ok := err.(scan.ErrorList); ok {
+		res.Diagnostics = append(res.Diagnostics, list...)
+	} else if err != nil {
+		res.Diagnostics.Add(token.Pos{File: name}, err.Error())
+	}
+	if file == nil {
+		return
+	}
+	res.Pkg = file.PkgName
+	for i := range file.Decls {
+		decl := &file.Decls[i]
+		pos := decl.Func
+		if !pos.IsValid() {
+			pos = token.Pos{File: name}
+		}
+		ssafn, err := codegen.Build(decl, file.PkgName, opts.Log)
+		if e, ok := err.(*scan.Error); ok {
+			// a type error has the position of its expression
+			res.Diagnostics = append(res.Diagnostics, e)
+			continue
+		} else if err != nil {
+			res.Diagnostics.Add(pos, err.Error())
+			continue
+		}
+		res.add(pos, ssafn, opts)
+	}
+}
+
+// compileDump compiles the functions of the SSA dump, src.
+func compileDump(name string, src io.Reader, opts Options, res *Result) {
+	pos := token.Pos{File: name}
+	fns, err := dump.Parse(name, src)
+	if err != nil {
+		res.Diagnostics.Add(pos, err.Error())
+		return
+	}
+	for _, fn := range fns {
+		ssafn, err := codegen.BuildDump(fn, opts.Log)
+		if err != nil {
+			res.Diagnostics.Add(pos, err.Error())
+			continue
+		}
+		res.add(pos, ssafn, opts)
+	}
+}
+
+// add generates the assembly, prototype and GIR of the compiled function,
+// ssafn, at pos.
+func (res *Result) add(pos token.Pos, ssafn *ssa.Func, opts Options) {
+	fn, err := generate(ssafn, opts)
+	if err != nil {
+		res.Diagnostics.Add(pos, err.Error())
+		return
+	}
+	res.Funcs = append(res.Funcs, fn)
+}
+
+// generate returns the assembly, prototype and GIR of the function, ssafn.
+func generate(ssafn *ssa.Func, opts Options) (fn *Func, err error) {
+	defer func() {
+		// the code generator panics on unimplemented ops and blocks
+		if r := recover(); r != nil {
+			fn, err = nil, fmt.Errorf("%v: %v", ssafn.Name, r)
+		}
+	}()
+	fn = &Func{Name: ssafn.Name, SSA: ssafn}
+	var ok bool
+	if fn.Asm, ok = codegen.GenAsm(ssafn); !ok {
+		return nil, fmt.Errorf("%v: can't generate assembly", ssafn.Name)
+	}
+	if fn.Proto, ok = codegen.GenGoProto(ssafn); !ok {
+		return nil, fmt.Errorf("%v: can't generate Go prototype", ssafn.Name)
+	}
+	switch {
+	case opts.Gir && opts.Pass != "":
+		fn.Gir, err = codegen.GenGirPass(ssafn, opts.Pass)
+	case opts.Gir:
+		fn.Gir, err = codegen.GenGir(ssafn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fn, nil
+}
@@ -0,0 +1,307 @@
+// Package dump parses the SSA dumps the Go compiler prints for the
+// function named by GOSSAFUNC, like
+//
+//	sum func(int, int) int
+//	  b1:
+//	    v1 = InitMem <mem>
+//	    v7 = Arg <int> {a}
+//	    v8 = Arg <int> {b}
+//	    v9 = Add64 <int> v7 v8
+//	  Ret v11
+//
+// It's a second frontend next to parse, codegen.BuildDump builds the
+// ssa.Func of a dump.
+package dump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Func is the dump of a function.
+type Func struct {
+	Name   string
+	Type   string // the signature, "func(int, int) int"
+	Blocks []*Block
+}
+
+// Block is a block, "b4: <- b2 b3", its values and its end,
+// "If v9 -> b3 b2".
+type Block struct {
+	ID      int
+	Preds   []int
+	Values  []*Value
+	Kind    string
+	Control int // the ID of the control value, 0 if none
+	Succs   []int
+	Likely  int // 1 if likely, -1 if unlikely
+	Line    int
+}
+
+// Value is a value, "v4 = Addr <*int> {a} v2".
+type Value struct {
+	ID     int
+	Op     string
+	Type   string
+	AuxInt string // as printed, "8", "3.5" or "true"
+	Aux    string // as printed, "a" or "\"str\""
+	Args   []int
+	Line   int
+}
+
+type parser struct {
+	name  string
+	line  int
+	funcs []*Func
+	fn    *Func
+	block *Block
+}
+
+// Parse parses the function dumps read from r, name is the file name
+// for errors. GOSSAFUNC prints a dump after each pass, lines of the
+// pass banners, "pass lower begin", are skipped.
+func Parse(name string, r io.Reader) ([]*Func, error) {
+	p := &parser{name: name}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.line++
+		if err := p.parseLine(scanner.Text()); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if p.block != nil && p.block.Kind == "" {
+		return nil, p.errorf("missing end of block b%d", p.block.ID)
+	}
+	return p.funcs, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.name, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseLine(line string) error {
+	text := strings.TrimSpace(line)
+	fields := strings.Fields(text)
+	switch {
+	case text == "":
+	case fields[0] == "pass" && len(fields) >= 3:
+	case line[0] != ' ' && line[0] != '\t':
+		// the function header, "sum func(int, int) int"
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], "func(") {
+			return p.errorf("expected function header, got %q", text)
+		}
+		if p.block != nil && p.block.Kind == "" {
+			return p.errorf("missing end of block b%d", p.block.ID)
+		}
+		p.fn = &Func{Name: fields[0], Type: strings.TrimSpace(text[len(fields[0]):])}
+		p.funcs = append(p.funcs, p.fn)
+		p.block = nil
+	case p.fn == nil:
+		return p.errorf("expected function header, got %q", text)
+	case strings.HasSuffix(fields[0], ":"):
+		return p.parseBlock(fields)
+	case p.block == nil || p.block.Kind != "":
+		return p.errorf("expected block, got %q", text)
+	case strings.Contains(text, " = "):
+		return p.parseValue(text)
+	default:
+		return p.parseEnd(fields)
+	}
+	return nil
+}
+
+// parseBlock parses the start of a block, "b4: <- b2 b3".
+func (p *parser) parseBlock(fields []string) error {
+	if p.block != nil && p.block.Kind == "" {
+		return p.errorf("missing end of block b%d", p.block.ID)
+	}
+	id, err := p.id("b", strings.TrimSuffix(fields[0], ":"))
+	if err != nil {
+		return err
+	}
+	p.block = &Block{ID: id, Line: p.line}
+	p.fn.Blocks = append(p.fn.Blocks, p.block)
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "<-", "DEAD":
+		default:
+			pred, err := p.id("b", fields[i])
+			if err != nil {
+				return err
+			}
+			p.block.Preds = append(p.block.Preds, pred)
+		}
+	}
+	return nil
+}
+
+// parseValue parses a value, "v4 = Addr <*int> [8] {a} v2 : AX". The line
+// number, "v4 (5) = ...", and register are ignored.
+func (p *parser) parseValue(text string) error {
+	eq := strings.Index(text, " = ")
+	id, err := p.id("v", strings.Fields(text[:eq])[0])
+	if err != nil {
+		return err
+	}
+	v := &Value{ID: id, Line: p.line}
+	rest := strings.TrimSpace(text[eq+len(" = "):])
+	if i := strings.IndexByte(rest, ' '); i >= 0 {
+		v.Op, rest = rest[:i], strings.TrimSpace(rest[i:])
+	} else {
+		v.Op, rest = rest, ""
+	}
+	if !strings.HasPrefix(rest, "<") {
+		return p.errorf("missing type of v%d", v.ID)
+	}
+	// the type ends at the first '>' ending a field, "<func() int>"
+	end := strings.Index(rest+" ", "> ")
+	if end < 0 {
+		return p.errorf("missing '>' after type of v%d", v.ID)
+	}
+	v.Type, rest = rest[1:end], strings.TrimSpace(rest[end+1:])
+	for rest != "" {
+		var field string
+		switch rest[0] {
+		case '[':
+			field, rest, err = p.delimited(rest, ']')
+			v.AuxInt = field
+		case '{':
+			field, rest, err = p.delimited(rest, '}')
+			v.Aux = field
+		case ':', '(':
+			// the register or the names of the value
+			rest = ""
+			continue
+		default:
+			if i := strings.IndexByte(rest, ' '); i >= 0 {
+				field, rest = rest[:i], strings.TrimSpace(rest[i:])
+			} else {
+				field, rest = rest, ""
+			}
+			var arg int
+			arg, err = p.id("v", field)
+			v.Args = append(v.Args, arg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	p.block.Values = append(p.block.Values, v)
+	return nil
+}
+
+// delimited returns the text of s up to the delimiter, end, and the
+// rest of s after it.
+func (p *parser) delimited(s string, end byte) (string, string, error) {
+	i := strings.IndexByte(s, end)
+	if i < 0 {
+		return "", "", p.errorf("missing %q in %q", end, s)
+	}
+	return s[1:i], strings.TrimSpace(s[i+1:]), nil
+}
+
+// parseEnd parses the end of a block, "If v9 -> b3 b2 (likely)".
+func (p *parser) parseEnd(fields []string) error {
+	b := p.block
+	b.Kind = fields[0]
+	succs := false
+	for _, field := range fields[1:] {
+		switch {
+		case field == "->":
+			succs = true
+		case field == "(likely)":
+			b.Likely = 1
+		case field == "(unlikely)":
+			b.Likely = -1
+		case succs:
+			succ, err := p.id("b", field)
+			if err != nil {
+				return err
+			}
+			b.Succs = append(b.Succs, succ)
+		case field == "nil":
+		case strings.HasPrefix(field, "v"):
+			control, err := p.id("v", field)
+			if err != nil {
+				return err
+			}
+			b.Control = control
+		default:
+			return p.errorf("unexpected %q in end of block b%d", field, b.ID)
+		}
+	}
+	return nil
+}
+
+// id returns the ID of the value or block, "v4" or "b2", with the prefix.
+func (p *parser) id(prefix, s string) (int, error) {
+	if !strings.HasPrefix(s, prefix) {
+		return 0, p.errorf("expected %s<n>, got %q", prefix, s)
+	}
+	id, err := strconv.Atoi(s[len(prefix):])
+	if err != nil || id <= 0 {
+		return 0, p.errorf("expected %s<n>, got %q", prefix, s)
+	}
+	return id, nil
+}
+
+// Block returns the block of fn with the ID, id, or nil.
+func (fn *Func) Block(id int) *Block {
+	for _, b := range fn.Blocks {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// Vars returns the aux naming each of the params and results of fn,
+// the fields of its signature, names, of which the first, params, are
+// params. A named field is named by its name and an unnamed result by
+// "~r" and its index, as the compiler names them. An unnamed param is
+// named by the next aux of an Arg or Addr value that names no other
+// field, the aux of a field named by no value is "".
+func (fn *Func) Vars(names []string, params int) []string {
+	aux := map[string]bool{}
+	var order []string
+	for _, b := range fn.Blocks {
+		for _, v := range b.Values {
+			if v.Op != "Arg" && v.Op != "Addr" || v.Aux == "" || aux[v.Aux] {
+				continue
+			}
+			aux[v.Aux] = true
+			order = append(order, v.Aux)
+		}
+	}
+	vars := make([]string, len(names))
+	used := map[string]bool{}
+	for i, name := range names {
+		if name == "" && i >= params {
+			name = fmt.Sprintf("~r%d", i)
+		}
+		if name != "" && aux[name] {
+			vars[i] = name
+			used[name] = true
+		}
+	}
+	for i, name := range names {
+		if name != "" || i >= params {
+			continue
+		}
+		for len(order) > 0 && (used[order[0]] || strings.HasPrefix(order[0], "~r")) {
+			order = order[1:]
+		}
+		if len(order) > 0 {
+			vars[i] = order[0]
+			used[order[0]] = true
+			order = order[1:]
+		}
+	}
+	return vars
+}
@@ -0,0 +1,302 @@
+package dump
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Gir returns the function, fn, as GIR source. Its values are variables
+// named by their IDs, "v4", and its blocks are labeled by theirs, "b2".
+// The params and results of its signature are named by their aux, as
+// Func.Vars finds them, with the '~' dropped, "~r2" is r2, and the other
+// aux are locals, like autos and spill slots, or strings.
+//
+// The registers of the values and the likeliness of the branches aren't
+// GIR and are dropped.
+func Gir(fn *Func) (string, error) {
+	g := &girPrinter{fn: fn, names: map[string]string{}}
+	if err := g.signature(); err != nil {
+		return "", err
+	}
+	g.branches()
+	for i, b := range fn.Blocks {
+		if i > 0 {
+			// the entry block is first and has no predecessors
+			fmt.Fprintf(&g.buf, "b%d:\n", b.ID)
+		}
+		if err := g.block(b); err != nil {
+			return "", err
+		}
+	}
+	fmt.Fprintf(&g.buf, "}\n")
+	return g.buf.String(), nil
+}
+
+// ParseAuxInt returns the auxint printed as an integer, a bool or a
+// float, the bits of its float64.
+func ParseAuxInt(s string) (int64, error) {
+	switch s {
+	case "true":
+		return 1, nil
+	case "false":
+		return 0, nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad auxint %v", s)
+	}
+	return int64(math.Float64bits(f)), nil
+}
+
+type girPrinter struct {
+	fn  *Func
+	buf bytes.Buffer
+	// the GIR names of the params, results and locals
+	names map[string]string
+	// the index in the predecessors of each block of its branches,
+	// in the order they're printed
+	preds map[int][]int
+}
+
+func (g *girPrinter) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%v: %v", g.fn.Name, fmt.Sprintf(format, args...))
+}
+
+// signature prints the function header, "func max(a int, b int) (r2 int) {".
+func (g *girPrinter) signature() error {
+	expr, err := goparser.ParseExpr(g.fn.Type)
+	if err != nil {
+		return g.errorf("bad signature %v", g.fn.Type)
+	}
+	sig, ok := expr.(*ast.FuncType)
+	if !ok {
+		return g.errorf("%v isn't a signature", g.fn.Type)
+	}
+	var names, typs []string
+	params := 0
+	for _, list := range []*ast.FieldList{sig.Params, sig.Results} {
+		if list == nil {
+			continue
+		}
+		for _, f := range list.List {
+			idents := f.Names
+			if len(idents) == 0 {
+				idents = []*ast.Ident{ast.NewIdent("")}
+			}
+			for _, ident := range idents {
+				names = append(names, ident.Name)
+				typs = append(typs, types.ExprString(f.Type))
+			}
+		}
+		if list == sig.Params {
+			params = len(names)
+		}
+	}
+	var s [2][]string
+	for i, dumpName := range g.fn.Vars(names, params) {
+		girName := names[i]
+		if girName == "" {
+			girName = strings.TrimPrefix(dumpName, "~")
+		}
+		prefix, list := "p", 0
+		if i >= params {
+			prefix, list = "r", 1
+		}
+		if girName == "" {
+			girName = fmt.Sprintf("%s%d", prefix, i)
+		}
+		if err := g.declare(dumpName, girName); err != nil {
+			return err
+		}
+		s[list] = append(s[list], girName+" "+typs[i])
+	}
+	fmt.Fprintf(&g.buf, "func %s(%s)", g.fn.Name, strings.Join(s[0], ", "))
+	if len(s[1]) > 0 {
+		fmt.Fprintf(&g.buf, " (%s)", strings.Join(s[1], ", "))
+	}
+	fmt.Fprintf(&g.buf, " {\n")
+	return nil
+}
+
+// declare names the param, result or local, dumpName, girName in the GIR.
+func (g *girPrinter) declare(dumpName, girName string) error {
+	if !token.IsIdentifier(girName) {
+		return g.errorf("%v isn't a GIR identifier", dumpName)
+	}
+	for _, name := range g.names {
+		if name == girName {
+			return g.errorf("%v redeclared", girName)
+		}
+	}
+	for _, b := range g.fn.Blocks {
+		for _, v := range b.Values {
+			if girName == fmt.Sprintf("v%d", v.ID) {
+				return g.errorf("%v has the name of a value", girName)
+			}
+		}
+	}
+	if dumpName != "" {
+		g.names[dumpName] = girName
+	}
+	return nil
+}
+
+// aux returns the GIR name of the aux, s, of a value. It's a param or
+// result, a quoted string or a local, like an auto or a spill slot of a
+// compiled function, named as in the dump with a leading '.' dropped.
+func (g *girPrinter) aux(s string) (string, error) {
+	if name, ok := g.names[s]; ok {
+		return name, nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if _, err := strconv.Unquote(s); err != nil {
+			return "", g.errorf("bad aux %v", s)
+		}
+		return s, nil
+	}
+	name := strings.TrimPrefix(s, ".")
+	if err := g.declare(s, name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// branches finds the order of the branches to each block in the GIR.
+// The args of a phi follow the order of the branches to its block and
+// in a dump they follow the order of its predecessors.
+func (g *girPrinter) branches() {
+	g.preds = map[int][]int{}
+	seen := map[[2]int]int{} // the edges of each pred and succ seen
+	for _, b := range g.fn.Blocks {
+		for _, id := range b.Succs {
+			succ := g.fn.Block(id)
+			edge := [2]int{b.ID, id}
+			// the nth edge from b is its nth occurrence in the preds
+			n := seen[edge]
+			seen[edge]++
+			if succ == nil {
+				continue
+			}
+			for i, pred := range succ.Preds {
+				if pred != b.ID {
+					continue
+				}
+				if n == 0 {
+					g.preds[id] = append(g.preds[id], i)
+					break
+				}
+				n--
+			}
+		}
+	}
+}
+
+// block prints the values and the end of the block, b.
+func (g *girPrinter) block(b *Block) error {
+	for _, v := range order(b) {
+		if err := g.value(b, v); err != nil {
+			return err
+		}
+	}
+	control := fmt.Sprintf("v%d", b.Control)
+	switch {
+	case b.Kind == "Plain" && len(b.Succs) == 1:
+		fmt.Fprintf(&g.buf, "\tgoto b%d\n", b.Succs[0])
+	case b.Kind == "Ret" && len(b.Succs) == 0:
+		// the control is the memory of the block
+		fmt.Fprintf(&g.buf, "\treturn\n")
+	case b.Kind == "If" && b.Control != 0 && len(b.Succs) == 2:
+		fmt.Fprintf(&g.buf, "\tif %s goto b%d else b%d\n", control, b.Succs[0], b.Succs[1])
+	case b.Control != 0 && len(b.Succs) == 2:
+		// a machine block, "NE v5 -> b2 b3"
+		fmt.Fprintf(&g.buf, "\tif %s %s goto b%d else b%d\n", b.Kind, control, b.Succs[0], b.Succs[1])
+	default:
+		return g.errorf("b%d: unsupported block kind %v", b.ID, b.Kind)
+	}
+	return nil
+}
+
+// value prints the value, v, of the block, b, "v4 = Addr <*int> {a} v2"
+// or "v10 = phi(v7, v8)".
+func (g *girPrinter) value(b *Block, v *Value) error {
+	if v.Op == "Phi" {
+		preds := g.preds[b.ID]
+		if len(v.Args) != len(b.Preds) || len(preds) != len(b.Preds) {
+			return g.errorf("v%d: %d phi args for %d predecessors", v.ID, len(v.Args), len(b.Preds))
+		}
+		var args []string
+		for _, i := range preds {
+			args = append(args, fmt.Sprintf("v%d", v.Args[i]))
+		}
+		fmt.Fprintf(&g.buf, "\tv%d = phi(%s)\n", v.ID, strings.Join(args, ", "))
+		return nil
+	}
+	fmt.Fprintf(&g.buf, "\tv%d = %s <%s>", v.ID, v.Op, v.Type)
+	if v.AuxInt != "" {
+		auxInt, err := ParseAuxInt(v.AuxInt)
+		if err != nil {
+			return g.errorf("v%d: %v", v.ID, err)
+		}
+		if auxInt != 0 {
+			fmt.Fprintf(&g.buf, " [%d]", auxInt)
+		}
+	}
+	if v.Aux != "" {
+		name, err := g.aux(v.Aux)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.buf, " {%s}", name)
+	}
+	for _, arg := range v.Args {
+		fmt.Fprintf(&g.buf, " v%d", arg)
+	}
+	fmt.Fprintf(&g.buf, "\n")
+	return nil
+}
+
+// order returns the values of the block, b, in the order they're
+// assigned in the GIR, the phis first and each other value after its
+// args in b. Before scheduling a value may be before its args.
+func order(b *Block) []*Value {
+	values := map[int]*Value{}
+	for _, v := range b.Values {
+		values[v.ID] = v
+	}
+	var ordered []*Value
+	done := map[int]bool{}
+	var visit func(v *Value)
+	visit = func(v *Value) {
+		if done[v.ID] {
+			return
+		}
+		done[v.ID] = true
+		if v.Op != "Phi" {
+			for _, arg := range v.Args {
+				if a := values[arg]; a != nil {
+					visit(a)
+				}
+			}
+		}
+		ordered = append(ordered, v)
+	}
+	for _, v := range b.Values {
+		if v.Op == "Phi" {
+			visit(v)
+		}
+	}
+	for _, v := range b.Values {
+		visit(v)
+	}
+	return ordered
+}
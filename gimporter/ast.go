@@ -0,0 +1,664 @@
+package gimporter
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/bjwbell/gir/gst"
+	"github.com/bjwbell/gir/scan"
+	girtoken "github.com/bjwbell/gir/token"
+	"github.com/bjwbell/gir/value"
+)
+
+var binaryOps = map[string]token.Token{
+	"+":  token.ADD,
+	"-":  token.SUB,
+	"*":  token.MUL,
+	"/":  token.QUO,
+	"%":  token.REM,
+	"&":  token.AND,
+	"|":  token.OR,
+	"^":  token.XOR,
+	"<<": token.SHL,
+	">>": token.SHR,
+	"&^": token.AND_NOT,
+	"&&": token.LAND,
+	"||": token.LOR,
+	"==": token.EQL,
+	"!=": token.NEQ,
+	"<":  token.LSS,
+	"<=": token.LEQ,
+	">":  token.GTR,
+	">=": token.GEQ,
+}
+
+var unaryOps = map[string]token.Token{
+	"+": token.ADD,
+	"-": token.SUB,
+	"^": token.XOR,
+	"!": token.NOT,
+}
+
+// Fset is the file set of the positions of the converted functions.
+// Each function has a file of its own, Fset.Position of a position in
+// it is the position of the GIR source the node was converted from.
+var Fset = token.NewFileSet()
+
+// posStride is the offset between the positions of a function's file,
+// the end of an identifier is before the next position.
+const posStride = 1 << 8
+
+// maxPos is the number of positions of a function's file, the nodes
+// past it have none.
+const maxPos = 1 << 16
+
+// converter converts a gst function to go/ast. The go/ast nodes have
+// positions in file, in the order they're converted.
+type converter struct {
+	file *token.File
+	off  int
+}
+
+// FuncDecl converts the gst function declaration, fnDecl, to a go/ast
+// function declaration. Locals aren't declared until Check.
+func FuncDecl(fnDecl *gst.FuncDecl) (*ast.FuncDecl, error) {
+	c := converter{file: Fset.AddFile(fnDecl.Func.File, -1, posStride*maxPos)}
+	fun := c.pos(fnDecl.Func)
+	name := c.ident(fnDecl.Name, fnDecl.NamePos)
+	params := c.fieldList(fnDecl.Params, "")
+	// unnamed results are named like go vet expects, so a bare
+	// return is valid and the asm can refer to them
+	results := c.fieldList(fnDecl.Results, "ret")
+	body, err := c.blockStmt(fnDecl.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(fnDecl.Results) == 0 && !isTerminating(body.List) {
+		// like Go, a function without results may omit the final return
+		body.List = append(body.List, &ast.ReturnStmt{})
+	}
+	decl := &ast.FuncDecl{
+		Name: name,
+		Type: &ast.FuncType{Func: fun, Params: params, Results: results},
+		Body: body,
+	}
+	return decl, nil
+}
+
+// pos returns the position of the next node converted, the GIR
+// position p. It's token.NoPos if p is unknown.
+func (c *converter) pos(p girtoken.Pos) token.Pos {
+	if !p.IsValid() || c.off+posStride >= c.file.Size() {
+		return token.NoPos
+	}
+	c.off += posStride
+	c.file.AddLineColumnInfo(c.off, p.File, p.Line, p.Col)
+	return c.file.Pos(c.off)
+}
+
+// ident returns the identifier name at the GIR position p.
+func (c *converter) ident(name string, p girtoken.Pos) *ast.Ident {
+	return &ast.Ident{NamePos: c.pos(p), Name: name}
+}
+
+// errorf returns the error at the position, pos, of a converted node as
+// a *scan.Error at its GIR position.
+func errorf(pos token.Pos, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if !pos.IsValid() {
+		return errors.New(msg)
+	}
+	p := Fset.Position(pos)
+	return &scan.Error{Pos: girtoken.Pos{File: p.Filename, Line: p.Line, Col: p.Column}, Msg: msg}
+}
+
+// fieldList converts params or results, unnamed fields are given
+// the names unnamed, unnamed1, ... if unnamed isn't empty.
+func (c *converter) fieldList(fields []gst.Field, unnamed string) *ast.FieldList {
+	list := &ast.FieldList{}
+	for i, field := range fields {
+		name := field.Name
+		if name == "" && unnamed != "" {
+			name = unnamed
+			if i > 0 {
+				name = fmt.Sprintf("%v%v", unnamed, i)
+			}
+		}
+		f := &ast.Field{}
+		if name != "" {
+			f.Names = []*ast.Ident{c.ident(name, field.Pos)}
+		}
+		f.Type = c.ident(field.Type, field.Pos)
+		list.List = append(list.List, f)
+	}
+	return list
+}
+
+// isTerminating reports whether the last statement of stmts transfers control.
+func isTerminating(stmts []ast.Stmt) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	stmt := stmts[len(stmts)-1]
+	if labeled, ok := stmt.(*ast.LabeledStmt); ok {
+		stmt = labeled.Stmt
+	}
+	switch stmt.(type) {
+	case *ast.ReturnStmt, *ast.BranchStmt, *ast.IfStmt:
+		return true
+	}
+	return false
+}
+
+func (c *converter) blockStmt(block *gst.BlockStmt) (*ast.BlockStmt, error) {
+	astBlock := &ast.BlockStmt{Lbrace: c.pos(block.Lbrace)}
+	for _, stmt := range block.List {
+		astStmt, err := c.stmt(stmt)
+		if err != nil {
+			return nil, err
+		}
+		astBlock.List = append(astBlock.List, astStmt)
+	}
+	return astBlock, nil
+}
+
+func (c *converter) stmt(stmt gst.Stmt) (ast.Stmt, error) {
+	switch stmt := stmt.(type) {
+	case *gst.RetStmt:
+		return &ast.ReturnStmt{Return: c.pos(stmt.Return)}, nil
+	case *gst.ExprStmt:
+		// an expression statement is evaluated and discarded,
+		// "_ = expr" since Go only allows calls as statements
+		var rhs []ast.Expr
+		for _, expr := range stmt.Exprs {
+			x, err := c.expr(expr, stmt.Start)
+			if err != nil {
+				return nil, err
+			}
+			rhs = append(rhs, x)
+		}
+		lhs := make([]ast.Expr, len(rhs))
+		for i := range lhs {
+			lhs[i] = c.ident("_", stmt.Start)
+		}
+		return &ast.AssignStmt{Lhs: lhs, Tok: token.ASSIGN, Rhs: rhs}, nil
+	case *gst.AssignStmt:
+		lhs := c.ident(stmt.Lhs.Name, stmt.Lhs.NamePos)
+		assign := c.pos(stmt.Assign)
+		var rhs ast.Expr
+		var err error
+		switch x := stmt.Rhs.(type) {
+		case *gst.CallExpr:
+			if x.Fun.Name == Phi {
+				rhs, err = c.phi(stmt.Lhs, x)
+			} else if x.Fun.Name == Rotl || x.Fun.Name == Rotr {
+				rhs, err = c.rotate(x)
+			} else {
+				rhs, err = c.expr(x, stmt.Assign)
+			}
+		case *gst.OpExpr:
+			rhs, err = c.op(stmt.Lhs, x)
+		default:
+			rhs, err = c.expr(x, stmt.Assign)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &ast.AssignStmt{
+			Lhs:    []ast.Expr{lhs},
+			TokPos: assign,
+			Tok:    token.ASSIGN,
+			Rhs:    []ast.Expr{rhs},
+		}, nil
+	case *gst.LabeledStmt:
+		label := c.ident(stmt.Label.Name, stmt.Label.NamePos)
+		s, err := c.stmt(stmt.Stmt)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.LabeledStmt{Label: label, Stmt: s}, nil
+	case *gst.GotoStmt:
+		return c.gotoStmt(stmt.Goto, stmt.Label), nil
+	case *gst.IfStmt:
+		ifPos := c.pos(stmt.If)
+		var kind *ast.SelectorExpr
+		if stmt.Kind != "" {
+			kind = &ast.SelectorExpr{X: c.ident("ssa", stmt.If), Sel: c.ident(stmt.Kind, stmt.If)}
+		}
+		cond, err := c.expr(stmt.Cond, stmt.If)
+		if err != nil {
+			return nil, err
+		}
+		if kind != nil {
+			control, ok := cond.(*ast.Ident)
+			if !ok {
+				return nil, fmt.Errorf("control of %v block %v isn't a variable", stmt.Kind, stmt.Cond.ProgString())
+			}
+			cond = &ast.CallExpr{Fun: kind, Args: []ast.Expr{control}}
+		}
+		// the form state.matchIfStmt expects,
+		// "if cond { goto yes } else { goto no }"
+		return &ast.IfStmt{
+			If:   ifPos,
+			Cond: cond,
+			Body: &ast.BlockStmt{List: []ast.Stmt{c.gotoStmt(stmt.Yes.NamePos, stmt.Yes)}},
+			Else: &ast.BlockStmt{List: []ast.Stmt{c.gotoStmt(stmt.No.NamePos, stmt.No)}},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown gst.Stmt: %T", stmt)
+	}
+}
+
+// gotoStmt returns the branch "goto label", its goto at the GIR
+// position p.
+func (c *converter) gotoStmt(p girtoken.Pos, label *gst.Ident) *ast.BranchStmt {
+	return &ast.BranchStmt{
+		TokPos: c.pos(p),
+		Tok:    token.GOTO,
+		Label:  c.ident(label.Name, label.NamePos),
+	}
+}
+
+// expr converts the expression, expr. Constants have no position of
+// their own, they're at the GIR position at, the position of the
+// operator or statement using them.
+func (c *converter) expr(expr value.Expr, at girtoken.Pos) (ast.Expr, error) {
+	switch expr := expr.(type) {
+	case *gst.Ident:
+		return c.ident(expr.Name, expr.NamePos), nil
+	case value.Int:
+		return &ast.BasicLit{ValuePos: c.pos(at), Kind: token.INT, Value: expr.ProgString()}, nil
+	case value.Float:
+		return &ast.BasicLit{ValuePos: c.pos(at), Kind: token.FLOAT, Value: expr.ProgString()}, nil
+	case *gst.UnaryExpr:
+		op, ok := unaryOps[expr.Op]
+		if !ok {
+			return nil, fmt.Errorf("unsupported unary operator %v", expr.Op)
+		}
+		opPos := c.pos(expr.OpPos)
+		x, err := c.expr(expr.X, expr.OpPos)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.UnaryExpr{OpPos: opPos, Op: op, X: x}, nil
+	case *gst.BinaryExpr:
+		op, ok := binaryOps[expr.Op]
+		if !ok {
+			return nil, fmt.Errorf("unsupported binary operator %v", expr.Op)
+		}
+		x, err := c.expr(expr.X, expr.OpPos)
+		if err != nil {
+			return nil, err
+		}
+		opPos := c.pos(expr.OpPos)
+		y, err := c.expr(expr.Y, expr.OpPos)
+		if err != nil {
+			return nil, err
+		}
+		return &ast.BinaryExpr{X: x, OpPos: opPos, Op: op, Y: y}, nil
+	case *gst.OpExpr:
+		return nil, fmt.Errorf("%v must be assigned to a variable", expr.Op)
+	case *gst.CallExpr:
+		if expr.Fun.Name == Phi || expr.Fun.Name == Rotl || expr.Fun.Name == Rotr {
+			return nil, fmt.Errorf("%v must be assigned to a variable", expr.ProgString())
+		}
+		if numericType(expr.Fun.Name) {
+			return c.conversion(expr)
+		}
+		if expr.Fun.Name == Sqrt {
+			return c.sqrt(expr)
+		}
+		return nil, fmt.Errorf("undefined: %v", expr.Fun.Name)
+	default:
+		return nil, fmt.Errorf("unsupported expression %v", expr.ProgString())
+	}
+}
+
+// Phi is the name of the phi builtin. In "x3 = phi(x1, x2)" x3 is x1
+// when control comes from the block's first predecessor and x2 when
+// it comes from the second.
+const Phi = "phi"
+
+// phi converts the phi assigned to lhs, its arguments must be variables.
+func (c *converter) phi(lhs *gst.Ident, call *gst.CallExpr) (ast.Expr, error) {
+	if lhs.Name == "_" {
+		return nil, fmt.Errorf("%v must be assigned to a variable", call.ProgString())
+	}
+	phi := &ast.CallExpr{Fun: c.ident(Phi, call.Fun.NamePos)}
+	for _, arg := range call.Args {
+		ident, ok := arg.(*gst.Ident)
+		if !ok || ident.Name == "_" {
+			return nil, fmt.Errorf("phi argument %v isn't a variable", arg.ProgString())
+		}
+		phi.Args = append(phi.Args, c.ident(ident.Name, ident.NamePos))
+	}
+	return phi, nil
+}
+
+// IsPhi returns expr as a call if it's a phi.
+func IsPhi(expr ast.Expr) (*ast.CallExpr, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, false
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	return call, ok && fun.Name == Phi
+}
+
+// Op is an SSA op, "v4 = Add64 <int64> [auxint] {aux} v2 v3". In go/ast
+// it's the call ssa.Add64(int64, auxint, aux, v2, v3), with aux nil if
+// the op has none. A string aux is the string literal, AuxString.
+type Op struct {
+	Call      *ast.CallExpr
+	Name      string
+	Type      string
+	AuxInt    int64
+	Aux       *ast.Ident
+	AuxString string
+	Args      []ast.Expr
+}
+
+// op converts the SSA op assigned to lhs.
+func (c *converter) op(lhs *gst.Ident, op *gst.OpExpr) (ast.Expr, error) {
+	if lhs.Name == "_" {
+		return nil, fmt.Errorf("%v must be assigned to a variable", op.Op)
+	}
+	fun := &ast.SelectorExpr{X: c.ident("ssa", op.OpPos), Sel: c.ident(op.Op, op.OpPos)}
+	typ, err := parser.ParseExpr(op.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%v: bad type %v", op.Op, op.Type)
+	}
+	var aux ast.Expr = ast.NewIdent("nil")
+	if op.Aux != nil {
+		aux = c.ident(op.Aux.Name, op.Aux.NamePos)
+	} else if op.AuxString != "" {
+		aux = &ast.BasicLit{ValuePos: c.pos(op.OpPos), Kind: token.STRING, Value: op.AuxString}
+	}
+	call := &ast.CallExpr{
+		Fun: fun,
+		Args: []ast.Expr{
+			typ,
+			&ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(op.AuxInt, 10)},
+			aux,
+		},
+	}
+	for _, arg := range op.Args {
+		call.Args = append(call.Args, c.ident(arg.Name, arg.NamePos))
+	}
+	return call, nil
+}
+
+// IsOp returns expr as an SSA op if it's one.
+func IsOp(expr ast.Expr) (*Op, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) < 3 {
+		return nil, false
+	}
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return nil, false
+	}
+	if pkg, ok := fun.X.(*ast.Ident); !ok || pkg.Name != "ssa" {
+		return nil, false
+	}
+	op := &Op{
+		Call: call,
+		Name: fun.Sel.Name,
+		Type: types.ExprString(call.Args[0]),
+		Args: call.Args[3:],
+	}
+	op.AuxInt, _ = strconv.ParseInt(call.Args[1].(*ast.BasicLit).Value, 10, 64)
+	switch aux := call.Args[2].(type) {
+	case *ast.Ident:
+		if aux.Name != "nil" {
+			op.Aux = aux
+		}
+	case *ast.BasicLit:
+		op.AuxString = aux.Value
+	}
+	return op, true
+}
+
+// Rotl and Rotr are the names of the rotate builtins. "r = rotl(x, k)"
+// is x rotated left by k bits, modulo the size of x in bits, rotr
+// rotates right. The checker sees the shift "x << k" in their place.
+const (
+	Rotl = "rotl"
+	Rotr = "rotr"
+)
+
+// rotate converts the call of a rotate builtin, its arguments are the
+// value and the count.
+func (c *converter) rotate(call *gst.CallExpr) (ast.Expr, error) {
+	if len(call.Args) != 2 {
+		return nil, fmt.Errorf("%v needs a value and a count", call.ProgString())
+	}
+	rot := &ast.CallExpr{Fun: c.ident(call.Fun.Name, call.Fun.NamePos)}
+	for _, arg := range call.Args {
+		x, err := c.expr(arg, call.Fun.NamePos)
+		if err != nil {
+			return nil, err
+		}
+		rot.Args = append(rot.Args, x)
+	}
+	return rot, nil
+}
+
+// IsRotate returns expr as a call if it's a rotate, with whether it
+// rotates left.
+func IsRotate(expr ast.Expr) (call *ast.CallExpr, left bool, ok bool) {
+	call, ok = expr.(*ast.CallExpr)
+	if !ok {
+		return nil, false, false
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	if !ok || (fun.Name != Rotl && fun.Name != Rotr) {
+		return nil, false, false
+	}
+	return call, fun.Name == Rotl, true
+}
+
+// conversion converts the conversion of its argument to the numeric
+// type of its name, "int32(x)".
+func (c *converter) conversion(call *gst.CallExpr) (ast.Expr, error) {
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("%v needs one value to convert", call.ProgString())
+	}
+	fun := c.ident(call.Fun.Name, call.Fun.NamePos)
+	x, err := c.expr(call.Args[0], call.Fun.NamePos)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.CallExpr{Fun: fun, Args: []ast.Expr{x}}, nil
+}
+
+// IsConversion returns expr as a call if it's a conversion to a numeric
+// type, like int32(x) or float64(n).
+func IsConversion(expr ast.Expr) (*ast.CallExpr, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return nil, false
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	return call, ok && numericType(fun.Name)
+}
+
+// numericType reports whether name is a predeclared integer or float
+// type.
+func numericType(name string) bool {
+	obj, ok := types.Universe.Lookup(name).(*types.TypeName)
+	if !ok {
+		return false
+	}
+	t, ok := obj.Type().(*types.Basic)
+	return ok && t.Info()&(types.IsInteger|types.IsFloat) != 0
+}
+
+// Sqrt is the name of the sqrt builtin, "func sqrt(x float64) float64",
+// the square root of x as math.Sqrt.
+const Sqrt = "sqrt"
+
+// sqrt converts the call of the sqrt builtin.
+func (c *converter) sqrt(call *gst.CallExpr) (ast.Expr, error) {
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("%v needs one value", call.ProgString())
+	}
+	fun := c.ident(Sqrt, call.Fun.NamePos)
+	x, err := c.expr(call.Args[0], call.Fun.NamePos)
+	if err != nil {
+		return nil, err
+	}
+	return &ast.CallExpr{Fun: fun, Args: []ast.Expr{x}}, nil
+}
+
+// IsSqrt returns expr as a call if it's a sqrt.
+func IsSqrt(expr ast.Expr) (*ast.CallExpr, bool) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, false
+	}
+	fun, ok := call.Fun.(*ast.Ident)
+	return call, ok && fun.Name == Sqrt
+}
+
+// IsBlockKind returns the kind and control of a block ending with the
+// branch "if Kind control goto yes else no", like "if NE v5 goto b2
+// else b3". In go/ast its condition is the call ssa.NE(v5).
+func IsBlockKind(cond ast.Expr) (kind string, control *ast.Ident, ok bool) {
+	call, ok := cond.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		return "", nil, false
+	}
+	fun, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return "", nil, false
+	}
+	if pkg, ok := fun.X.(*ast.Ident); !ok || pkg.Name != "ssa" {
+		return "", nil, false
+	}
+	control, ok = call.Args[0].(*ast.Ident)
+	return fun.Sel.Name, control, ok
+}
+
+// isSSA reports whether expr is a phi or an SSA op, which aren't Go.
+func isSSA(expr ast.Expr) bool {
+	_, phi := IsPhi(expr)
+	_, op := IsOp(expr)
+	return phi || op
+}
+
+// Check type checks the function, decl, as the only declaration in the
+// package pkgName and returns its types.Func and the type information
+// of its body. The locals of decl are declared at the start of its body.
+func Check(pkgName string, decl *ast.FuncDecl) (*types.Func, *types.Info, error) {
+	declareLocals(decl)
+	file := &ast.File{
+		Name:  ast.NewIdent(pkgName),
+		Decls: []ast.Decl{decl},
+	}
+	info := &types.Info{
+		Types:  map[ast.Expr]types.TypeAndValue{},
+		Defs:   map[*ast.Ident]types.Object{},
+		Uses:   map[*ast.Ident]types.Object{},
+		Scopes: map[ast.Node]*types.Scope{},
+	}
+	// phis and ops aren't Go, the checker sees "x3 = x3" in place of
+	// "x3 = phi(x1, x2)", they're checked by checkSSA
+	stmts := assignments(decl.Body, func(stmt *ast.AssignStmt) bool {
+		return isSSA(stmt.Rhs[0])
+	})
+	calls := make([]ast.Expr, len(stmts))
+	for i, stmt := range stmts {
+		calls[i] = stmt.Rhs[0]
+		stmt.Rhs[0] = &ast.Ident{NamePos: calls[i].Pos(), Name: stmt.Lhs[0].(*ast.Ident).Name}
+	}
+	// and "x << k" in place of "rotl(x, k)"
+	rots := assignments(decl.Body, func(stmt *ast.AssignStmt) bool {
+		_, _, ok := IsRotate(stmt.Rhs[0])
+		return ok
+	})
+	rotCalls := make([]*ast.CallExpr, len(rots))
+	shifts := make([]*ast.BinaryExpr, len(rots))
+	for i, stmt := range rots {
+		call := stmt.Rhs[0].(*ast.CallExpr)
+		rotCalls[i] = call
+		shifts[i] = &ast.BinaryExpr{X: call.Args[0], OpPos: call.Pos(), Op: token.SHL, Y: call.Args[1]}
+		stmt.Rhs[0] = shifts[i]
+	}
+	// and "if true" in place of "if NE v5"
+	ifs := blockKinds(decl.Body)
+	conds := make([]ast.Expr, len(ifs))
+	for i, stmt := range ifs {
+		conds[i] = stmt.Cond
+		stmt.Cond = &ast.Ident{NamePos: conds[i].Pos(), Name: "true"}
+	}
+	var firstErr error
+	conf := types.Config{
+		Error: func(err error) {
+			// soft errors, like unused variables, are allowed
+			if firstErr == nil && !isSoft(err) {
+				// the error at the GIR position of the node
+				if terr, ok := err.(types.Error); ok {
+					err = errorf(terr.Pos, "%s", terr.Msg)
+				}
+				firstErr = err
+			}
+		},
+	}
+	types.NewChecker(&conf, Fset, newPackage(pkgName), info).Files([]*ast.File{file})
+	for i, stmt := range stmts {
+		stmt.Rhs[0] = calls[i]
+	}
+	for i, stmt := range ifs {
+		stmt.Cond = conds[i]
+	}
+	for i, stmt := range rots {
+		stmt.Rhs[0] = rotCalls[i]
+		// a rotate of constants isn't the constant shift
+		tv := info.Types[shifts[i]]
+		tv.Value = nil
+		info.Types[rotCalls[i]] = tv
+	}
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	fn, ok := info.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil, nil, fmt.Errorf("%v is not a function", decl.Name.Name)
+	}
+	if err := checkSSA(info, info.Scopes[decl.Type], stmts, ifs); err != nil {
+		return nil, nil, err
+	}
+	if err := checkShifts(info, decl.Body); err != nil {
+		return nil, nil, err
+	}
+	return fn, info, nil
+}
+
+// checkShifts checks that the counts of the shifts of body are unsigned
+// or constant. Go panics on a negative count, gir has no panics.
+func checkShifts(info *types.Info, body *ast.BlockStmt) error {
+	var err error
+	ast.Inspect(body, func(n ast.Node) bool {
+		shift, ok := n.(*ast.BinaryExpr)
+		if !ok || err != nil || shift.Op != token.SHL && shift.Op != token.SHR {
+			return err == nil
+		}
+		t, ok := info.TypeOf(shift.Y).(*types.Basic)
+		if ok && info.Types[shift.Y].Value == nil && t.Info()&types.IsUnsigned == 0 {
+			err = errorf(shift.Y.Pos(), "shift count %v must be unsigned", types.ExprString(shift.Y))
+		}
+		return err == nil
+	})
+	return err
+}
+
+func isSoft(err error) bool {
+	terr, ok := err.(types.Error)
+	return ok && terr.Soft
+}
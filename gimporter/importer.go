@@ -1,19 +1,90 @@
 package gimporter
 
 import (
-	"github.com/bjwbell/gir/gst"
-	"go/types"
+	"fmt"
 	"go/token"
+	"go/types"
+
+	"github.com/bjwbell/gir/gst"
 )
 
-func ParseFuncDecl(fnDecl *gst.FuncDecl) (*types.Func, bool) {
+func ParseFuncDecl(fnDecl *gst.FuncDecl) (*types.Func, error) {
 	var fn *types.Func
 	var pkg *types.Package
 	pkg = nil
 	name := fnDecl.Name
+	params, err := fieldVars(pkg, fnDecl.Params)
+	if err != nil {
+		return nil, fmt.Errorf("params of %v: %v", name, err)
+	}
+	results, err := fieldVars(pkg, fnDecl.Results)
+	if err != nil {
+		return nil, fmt.Errorf("results of %v: %v", name, err)
+	}
 	var sig *types.Signature
-	sig = types.NewSignature(nil, nil, nil, false)
+	sig = types.NewSignature(nil, params, results, false)
 	var pos token.Pos
 	fn = types.NewFunc(pos, pkg, name, sig)
-	return fn, true
+	return fn, nil
+}
+
+// fieldVars returns the tuple of vars for the params or results, fields.
+func fieldVars(pkg *types.Package, fields []gst.Field) (*types.Tuple, error) {
+	var vars []*types.Var
+	var pos token.Pos
+	for _, field := range fields {
+		t, err := LookupType(field.Type)
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, types.NewVar(pos, pkg, field.Name, t))
+	}
+	return types.NewTuple(vars...), nil
+}
+
+// LookupType returns the type named name, a predeclared type, a pointer
+// type, "*int64", or a type of SSA values, mem or flags.
+func LookupType(name string) (types.Type, error) {
+	tv, err := types.Eval(token.NewFileSet(), ssaTypes, token.NoPos, name)
+	if err != nil {
+		return nil, fmt.Errorf("undefined type %v", name)
+	}
+	if !tv.IsType() {
+		return nil, fmt.Errorf("%v is not a type", name)
+	}
+	return tv.Type, nil
+}
+
+// ssaTypes is the package of the types of SSA values that aren't Go
+// types, the memory, mem, and the flags, flags, and of the sqrt builtin.
+// The package of a checked function declares them too.
+var ssaTypes = func() *types.Package {
+	pkg := types.NewPackage("ssa", "ssa")
+	for _, name := range []string{"mem", "flags"} {
+		obj := types.NewTypeName(token.NoPos, pkg, name, nil)
+		types.NewNamed(obj, types.NewStruct(nil, nil), nil)
+		pkg.Scope().Insert(obj)
+	}
+	x := types.NewVar(token.NoPos, pkg, "x", types.Typ[types.Float64])
+	r := types.NewVar(token.NoPos, pkg, "", types.Typ[types.Float64])
+	sig := types.NewSignature(nil, types.NewTuple(x), types.NewTuple(r), false)
+	pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, Sqrt, sig))
+	pkg.MarkComplete()
+	return pkg
+}()
+
+// Mem and Flags are the types of memory and flags values.
+var (
+	Mem   = ssaTypes.Scope().Lookup("mem").Type()
+	Flags = ssaTypes.Scope().Lookup("flags").Type()
+)
+
+// newPackage returns a package named name declaring the SSA types and
+// the sqrt builtin.
+func newPackage(name string) *types.Package {
+	pkg := types.NewPackage(name, name)
+	for _, obj := range []types.Object{ssaTypes.Scope().Lookup("mem"), ssaTypes.Scope().Lookup("flags"), ssaTypes.Scope().Lookup(Sqrt)} {
+		pkg.Scope().Insert(obj)
+	}
+	return pkg
 }
@@ -0,0 +1,289 @@
+package gimporter
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// declareLocals declares the locals of decl, the names assigned or
+// named by an aux that aren't params or results, with "var" at the
+// start of its body. GIR blocks can be in any order and phis use values
+// of later blocks, so a local may be used above its assignment. The
+// type of a local is inferred from the assignments to it, locals whose
+// type can't be inferred are left undeclared for Check to report.
+func declareLocals(decl *ast.FuncDecl) {
+	params := map[string]bool{}
+	for _, list := range []*ast.FieldList{decl.Type.Params, decl.Type.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				params[name.Name] = true
+			}
+		}
+	}
+	var names []string
+	assigns := map[string][]*ast.AssignStmt{}
+	assignments(decl.Body, func(stmt *ast.AssignStmt) bool {
+		name := stmt.Lhs[0].(*ast.Ident).Name
+		if name == "_" || params[name] {
+			return false
+		}
+		if _, ok := assigns[name]; !ok {
+			names = append(names, name)
+		}
+		assigns[name] = append(assigns[name], stmt)
+		return false
+	})
+	locals := inferTypes(decl.Type, params, names, assigns)
+	// the autos and spill slots of a compiled function are only named
+	// by the aux of ops, an Addr of one points to it and a spill to one
+	// has its type
+	assignments(decl.Body, func(stmt *ast.AssignStmt) bool {
+		op, ok := IsOp(stmt.Rhs[0])
+		if !ok || op.Aux == nil || params[op.Aux.Name] || assigns[op.Aux.Name] != nil || locals[op.Aux.Name] != nil {
+			return false
+		}
+		t, err := LookupType(op.Type)
+		if err != nil {
+			return false
+		}
+		if ptr, ok := t.(*types.Pointer); ok && op.Name == "Addr" {
+			t = ptr.Elem()
+		} else if op.Name != "StoreReg" {
+			return false
+		}
+		names = append(names, op.Aux.Name)
+		locals[op.Aux.Name] = t
+		return false
+	})
+	var decls []ast.Stmt
+	for _, name := range names {
+		if t := locals[name]; t != nil {
+			decls = append(decls, varDecl(name, t))
+		}
+	}
+	decl.Body.List = append(decls, decl.Body.List...)
+}
+
+// assignments returns the single assignments of body matching keep.
+func assignments(body *ast.BlockStmt, keep func(*ast.AssignStmt) bool) []*ast.AssignStmt {
+	var stmts []*ast.AssignStmt
+	for _, stmt := range body.List {
+		if labeled, ok := stmt.(*ast.LabeledStmt); ok {
+			stmt = labeled.Stmt
+		}
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+			continue
+		}
+		if _, ok := assign.Lhs[0].(*ast.Ident); ok && keep(assign) {
+			stmts = append(stmts, assign)
+		}
+	}
+	return stmts
+}
+
+// inferTypes returns the default types of the locals, names, assigned
+// by assigns in a function with the signature sig and the params. Each
+// local is declared from the first assignment to it whose locals can
+// be declared before it, "var name = value", and the declarations are
+// type checked once. The value of a phi is the first of its arguments
+// that can be declared, an op has its type and a rotate the type of the
+// value rotated. A local whose declaration doesn't type check has no
+// type.
+func inferTypes(sig *ast.FuncType, params map[string]bool, names []string, assigns map[string][]*ast.AssignStmt) map[string]types.Type {
+	body := &ast.BlockStmt{}
+	idents := map[string]*ast.Ident{}
+	// declaring marks the locals being declared, an assignment using
+	// one of them can't declare another
+	declaring := map[string]bool{}
+	var declare func(name string) bool
+	// uses declares the locals used by expr
+	uses := func(expr ast.Expr) bool {
+		ok := true
+		ast.Inspect(expr, func(n ast.Node) bool {
+			if ident, isIdent := n.(*ast.Ident); isIdent && assigns[ident.Name] != nil {
+				ok = declare(ident.Name)
+			}
+			return ok
+		})
+		return ok
+	}
+	declare = func(name string) bool {
+		if idents[name] != nil {
+			return true
+		}
+		if declaring[name] {
+			return false
+		}
+		declaring[name] = true
+		defer delete(declaring, name)
+		for _, stmt := range assigns[name] {
+			expr := stmt.Rhs[0]
+			if op, ok := IsOp(expr); ok {
+				t, err := LookupType(op.Type)
+				if err != nil {
+					continue
+				}
+				decl := varDecl(name, t)
+				idents[name] = decl.(*ast.DeclStmt).Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Names[0]
+				body.List = append(body.List, decl)
+				return true
+			}
+			if call, _, ok := IsRotate(expr); ok {
+				expr = call.Args[0]
+			}
+			if call, ok := IsPhi(expr); ok {
+				expr = nil
+				for _, arg := range call.Args {
+					if ident, ok := arg.(*ast.Ident); ok && (params[ident.Name] || assigns[ident.Name] != nil && declare(ident.Name)) {
+						expr = arg
+						break
+					}
+				}
+				if expr == nil {
+					continue
+				}
+			} else if !uses(expr) {
+				continue
+			}
+			idents[name] = ast.NewIdent(name)
+			body.List = append(body.List, &ast.DeclStmt{Decl: &ast.GenDecl{
+				Tok: token.VAR,
+				Specs: []ast.Spec{&ast.ValueSpec{
+					Names:  []*ast.Ident{idents[name]},
+					Values: []ast.Expr{expr},
+				}},
+			}})
+			return true
+		}
+		return false
+	}
+	for _, name := range names {
+		declare(name)
+	}
+	body.List = append(body.List, &ast.ReturnStmt{})
+	file := &ast.File{
+		Name: ast.NewIdent("infer"),
+		Decls: []ast.Decl{&ast.FuncDecl{
+			Name: ast.NewIdent("_"),
+			Type: sig,
+			Body: body,
+		}},
+	}
+	info := &types.Info{Defs: map[*ast.Ident]types.Object{}}
+	conf := types.Config{Error: func(error) {}}
+	types.NewChecker(&conf, Fset, newPackage("infer"), info).Files([]*ast.File{file})
+	locals := map[string]types.Type{}
+	for name, ident := range idents {
+		if obj := info.Defs[ident]; obj != nil && obj.Type() != types.Typ[types.Invalid] {
+			locals[name] = types.Default(obj.Type())
+		}
+	}
+	return locals
+}
+
+// varDecl declares name with the type t, "var name t".
+func varDecl(name string, t types.Type) ast.Stmt {
+	typ, err := parser.ParseExpr(types.TypeString(t, func(*types.Package) string { return "" }))
+	if err != nil {
+		panic(err)
+	}
+	return &ast.DeclStmt{Decl: &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  typ,
+		}},
+	}}
+}
+
+// checkSSA checks the phis and ops assigned by stmts and records
+// their types and the uses of their variables in info. The arguments
+// of a phi are variables of scope with the type of the phi's variable,
+// an op is a known op, its arguments and aux are variables, or its aux
+// a string, and its type is the type of the op's variable. The machine
+// blocks ending with ifs are of known kinds and their controls are
+// variables too.
+func checkSSA(info *types.Info, scope *types.Scope, stmts []*ast.AssignStmt, ifs []*ast.IfStmt) error {
+	lookup := func(ident *ast.Ident) (*types.Var, error) {
+		v, ok := scope.Lookup(ident.Name).(*types.Var)
+		if !ok {
+			return nil, errorf(ident.Pos(), "undefined: %v", ident.Name)
+		}
+		info.Uses[ident] = v
+		return v, nil
+	}
+	for _, stmt := range stmts {
+		lhs := stmt.Lhs[0].(*ast.Ident)
+		t := info.TypeOf(lhs)
+		if op, ok := IsOp(stmt.Rhs[0]); ok {
+			if _, ok := LookupOp(op.Name); !ok {
+				return errorf(op.Call.Pos(), "unknown op %v", op.Name)
+			}
+			opType, err := LookupType(op.Type)
+			if err != nil {
+				return errorf(op.Call.Pos(), "%v", err)
+			}
+			if !types.Identical(opType, t) {
+				return errorf(op.Call.Pos(), "%v has type %v, %v has type %v", op.Name, opType, lhs.Name, t)
+			}
+			if op.Aux != nil {
+				if _, err := lookup(op.Aux); err != nil {
+					return err
+				}
+			}
+			if _, err := strconv.Unquote(op.AuxString); op.AuxString != "" && err != nil {
+				return errorf(op.Call.Args[2].Pos(), "bad aux %v", op.AuxString)
+			}
+			for _, arg := range op.Args {
+				if _, err := lookup(arg.(*ast.Ident)); err != nil {
+					return err
+				}
+			}
+			info.Types[op.Call] = types.TypeAndValue{Type: t}
+			continue
+		}
+		call, _ := IsPhi(stmt.Rhs[0])
+		for _, arg := range call.Args {
+			v, err := lookup(arg.(*ast.Ident))
+			if err != nil {
+				return err
+			}
+			if !types.Identical(v.Type(), t) {
+				return errorf(arg.Pos(), "phi argument %v has type %v, %v has type %v", v.Name(), v.Type(), lhs.Name, t)
+			}
+		}
+		info.Types[call] = types.TypeAndValue{Type: t}
+	}
+	for _, stmt := range ifs {
+		kind, control, _ := IsBlockKind(stmt.Cond)
+		if _, ok := LookupBlockKind(kind); !ok {
+			return errorf(stmt.Cond.Pos(), "unknown block kind %v", kind)
+		}
+		if _, err := lookup(control); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockKinds returns the if statements of body ending blocks of a
+// machine kind, "if NE v5 goto b2 else b3".
+func blockKinds(body *ast.BlockStmt) []*ast.IfStmt {
+	var ifs []*ast.IfStmt
+	ast.Inspect(body, func(n ast.Node) bool {
+		if stmt, ok := n.(*ast.IfStmt); ok {
+			if _, _, ok := IsBlockKind(stmt.Cond); ok {
+				ifs = append(ifs, stmt)
+			}
+		}
+		return true
+	})
+	return ifs
+}
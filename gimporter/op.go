@@ -0,0 +1,507 @@
+package gimporter
+
+import "github.com/bjwbell/ssa"
+
+// genericOps are the machine independent ops, of GIR lowered to SSA
+// and of SSA dumps before the lower pass. ssa.Op has no count of the
+// ops to range over, so the ops known by name are listed.
+var genericOps = []ssa.Op{
+	ssa.OpAdd16,
+	ssa.OpAdd32,
+	ssa.OpAdd32F,
+	ssa.OpAdd64,
+	ssa.OpAdd64F,
+	ssa.OpAdd8,
+	ssa.OpAddPtr,
+	ssa.OpAddr,
+	ssa.OpAnd16,
+	ssa.OpAnd32,
+	ssa.OpAnd64,
+	ssa.OpAnd8,
+	ssa.OpArg,
+	ssa.OpCom16,
+	ssa.OpCom32,
+	ssa.OpCom64,
+	ssa.OpCom8,
+	ssa.OpComplexImag,
+	ssa.OpComplexMake,
+	ssa.OpComplexReal,
+	ssa.OpConst16,
+	ssa.OpConst32,
+	ssa.OpConst32F,
+	ssa.OpConst64,
+	ssa.OpConst64F,
+	ssa.OpConst8,
+	ssa.OpConstBool,
+	ssa.OpConstInterface,
+	ssa.OpConstNil,
+	ssa.OpConstSlice,
+	ssa.OpConstString,
+	ssa.OpCopy,
+	ssa.OpCvt32Fto32,
+	ssa.OpCvt32Fto64,
+	ssa.OpCvt32Fto64F,
+	ssa.OpCvt32to32F,
+	ssa.OpCvt32to64F,
+	ssa.OpCvt64Fto32,
+	ssa.OpCvt64Fto32F,
+	ssa.OpCvt64Fto64,
+	ssa.OpCvt64to32F,
+	ssa.OpCvt64to64F,
+	ssa.OpDiv16,
+	ssa.OpDiv16u,
+	ssa.OpDiv32,
+	ssa.OpDiv32F,
+	ssa.OpDiv32u,
+	ssa.OpDiv64,
+	ssa.OpDiv64F,
+	ssa.OpDiv64u,
+	ssa.OpDiv8,
+	ssa.OpDiv8u,
+	ssa.OpEq16,
+	ssa.OpEq32,
+	ssa.OpEq32F,
+	ssa.OpEq64,
+	ssa.OpEq64F,
+	ssa.OpEq8,
+	ssa.OpEqInter,
+	ssa.OpEqPtr,
+	ssa.OpEqSlice,
+	ssa.OpFwdRef,
+	ssa.OpGeq16,
+	ssa.OpGeq16U,
+	ssa.OpGeq32,
+	ssa.OpGeq32F,
+	ssa.OpGeq32U,
+	ssa.OpGeq64,
+	ssa.OpGeq64F,
+	ssa.OpGeq64U,
+	ssa.OpGeq8,
+	ssa.OpGeq8U,
+	ssa.OpGetClosurePtr,
+	ssa.OpGreater16,
+	ssa.OpGreater16U,
+	ssa.OpGreater32,
+	ssa.OpGreater32F,
+	ssa.OpGreater32U,
+	ssa.OpGreater64,
+	ssa.OpGreater64F,
+	ssa.OpGreater64U,
+	ssa.OpGreater8,
+	ssa.OpGreater8U,
+	ssa.OpHmul16,
+	ssa.OpHmul16u,
+	ssa.OpHmul32,
+	ssa.OpHmul32u,
+	ssa.OpHmul8,
+	ssa.OpHmul8u,
+	ssa.OpInitMem,
+	ssa.OpLeq16,
+	ssa.OpLeq16U,
+	ssa.OpLeq32,
+	ssa.OpLeq32F,
+	ssa.OpLeq32U,
+	ssa.OpLeq64,
+	ssa.OpLeq64F,
+	ssa.OpLeq64U,
+	ssa.OpLeq8,
+	ssa.OpLeq8U,
+	ssa.OpLess16,
+	ssa.OpLess16U,
+	ssa.OpLess32,
+	ssa.OpLess32F,
+	ssa.OpLess32U,
+	ssa.OpLess64,
+	ssa.OpLess64F,
+	ssa.OpLess64U,
+	ssa.OpLess8,
+	ssa.OpLess8U,
+	ssa.OpLoad,
+	ssa.OpLoadReg,
+	ssa.OpLrot16,
+	ssa.OpLrot32,
+	ssa.OpLrot64,
+	ssa.OpLrot8,
+	ssa.OpLsh16x16,
+	ssa.OpLsh16x32,
+	ssa.OpLsh16x64,
+	ssa.OpLsh16x8,
+	ssa.OpLsh32x16,
+	ssa.OpLsh32x32,
+	ssa.OpLsh32x64,
+	ssa.OpLsh32x8,
+	ssa.OpLsh64x16,
+	ssa.OpLsh64x32,
+	ssa.OpLsh64x64,
+	ssa.OpLsh64x8,
+	ssa.OpLsh8x16,
+	ssa.OpLsh8x32,
+	ssa.OpLsh8x64,
+	ssa.OpLsh8x8,
+	ssa.OpMod16,
+	ssa.OpMod16u,
+	ssa.OpMod32,
+	ssa.OpMod32u,
+	ssa.OpMod64,
+	ssa.OpMod64u,
+	ssa.OpMod8,
+	ssa.OpMod8u,
+	ssa.OpMul16,
+	ssa.OpMul32,
+	ssa.OpMul32F,
+	ssa.OpMul64,
+	ssa.OpMul64F,
+	ssa.OpMul8,
+	ssa.OpNeg16,
+	ssa.OpNeg32,
+	ssa.OpNeg32F,
+	ssa.OpNeg64,
+	ssa.OpNeg64F,
+	ssa.OpNeg8,
+	ssa.OpNeq16,
+	ssa.OpNeq32,
+	ssa.OpNeq32F,
+	ssa.OpNeq64,
+	ssa.OpNeq64F,
+	ssa.OpNeq8,
+	ssa.OpNeqInter,
+	ssa.OpNeqPtr,
+	ssa.OpNeqSlice,
+	ssa.OpNot,
+	ssa.OpOffPtr,
+	ssa.OpOr16,
+	ssa.OpOr32,
+	ssa.OpOr64,
+	ssa.OpOr8,
+	ssa.OpPhi,
+	ssa.OpPtrIndex,
+	ssa.OpRsh16Ux16,
+	ssa.OpRsh16Ux32,
+	ssa.OpRsh16Ux64,
+	ssa.OpRsh16Ux8,
+	ssa.OpRsh16x16,
+	ssa.OpRsh16x32,
+	ssa.OpRsh16x64,
+	ssa.OpRsh16x8,
+	ssa.OpRsh32Ux16,
+	ssa.OpRsh32Ux32,
+	ssa.OpRsh32Ux64,
+	ssa.OpRsh32Ux8,
+	ssa.OpRsh32x16,
+	ssa.OpRsh32x32,
+	ssa.OpRsh32x64,
+	ssa.OpRsh32x8,
+	ssa.OpRsh64Ux16,
+	ssa.OpRsh64Ux32,
+	ssa.OpRsh64Ux64,
+	ssa.OpRsh64Ux8,
+	ssa.OpRsh64x16,
+	ssa.OpRsh64x32,
+	ssa.OpRsh64x64,
+	ssa.OpRsh64x8,
+	ssa.OpRsh8Ux16,
+	ssa.OpRsh8Ux32,
+	ssa.OpRsh8Ux64,
+	ssa.OpRsh8Ux8,
+	ssa.OpRsh8x16,
+	ssa.OpRsh8x32,
+	ssa.OpRsh8x64,
+	ssa.OpRsh8x8,
+	ssa.OpSB,
+	ssa.OpSP,
+	ssa.OpSignExt16to32,
+	ssa.OpSignExt16to64,
+	ssa.OpSignExt32to64,
+	ssa.OpSignExt8to16,
+	ssa.OpSignExt8to32,
+	ssa.OpSignExt8to64,
+	ssa.OpSliceLen,
+	ssa.OpSlicePtr,
+	ssa.OpSqrt,
+	ssa.OpStore,
+	ssa.OpStoreReg,
+	ssa.OpSub16,
+	ssa.OpSub32,
+	ssa.OpSub32F,
+	ssa.OpSub64,
+	ssa.OpSub64F,
+	ssa.OpSub8,
+	ssa.OpTrunc16to8,
+	ssa.OpTrunc32to16,
+	ssa.OpTrunc32to8,
+	ssa.OpTrunc64to16,
+	ssa.OpTrunc64to32,
+	ssa.OpTrunc64to8,
+	ssa.OpVarDef,
+	ssa.OpVarKill,
+	ssa.OpXor16,
+	ssa.OpXor32,
+	ssa.OpXor64,
+	ssa.OpXor8,
+	ssa.OpZeroExt16to32,
+	ssa.OpZeroExt16to64,
+	ssa.OpZeroExt32to64,
+	ssa.OpZeroExt8to16,
+	ssa.OpZeroExt8to32,
+	ssa.OpZeroExt8to64,
+}
+
+// amd64Ops are the machine ops codegen assembles. Their names in SSA
+// dumps aren't prefixed by the arch and other archs share some of the
+// names, so they're listed with the "AMD64" prefix as another name.
+var amd64Ops = []ssa.Op{
+	ssa.OpAMD64ADDL,
+	ssa.OpAMD64ADDLconst,
+	ssa.OpAMD64ADDQ,
+	ssa.OpAMD64ADDQconst,
+	ssa.OpAMD64ADDSD,
+	ssa.OpAMD64ADDSS,
+	ssa.OpAMD64ANDL,
+	ssa.OpAMD64ANDLconst,
+	ssa.OpAMD64ANDQ,
+	ssa.OpAMD64ANDQconst,
+	ssa.OpAMD64CALLclosure,
+	ssa.OpAMD64CALLdefer,
+	ssa.OpAMD64CALLgo,
+	ssa.OpAMD64CALLinter,
+	ssa.OpAMD64CALLstatic,
+	ssa.OpAMD64CMPB,
+	ssa.OpAMD64CMPBconst,
+	ssa.OpAMD64CMPL,
+	ssa.OpAMD64CMPLconst,
+	ssa.OpAMD64CMPQ,
+	ssa.OpAMD64CMPQconst,
+	ssa.OpAMD64CMPW,
+	ssa.OpAMD64CMPWconst,
+	ssa.OpAMD64CVTSD2SS,
+	ssa.OpAMD64CVTSL2SD,
+	ssa.OpAMD64CVTSL2SS,
+	ssa.OpAMD64CVTSQ2SD,
+	ssa.OpAMD64CVTSQ2SS,
+	ssa.OpAMD64CVTSS2SD,
+	ssa.OpAMD64CVTTSD2SL,
+	ssa.OpAMD64CVTTSD2SQ,
+	ssa.OpAMD64CVTTSS2SL,
+	ssa.OpAMD64CVTTSS2SQ,
+	ssa.OpAMD64DIVL,
+	ssa.OpAMD64DIVLU,
+	ssa.OpAMD64DIVQ,
+	ssa.OpAMD64DIVQU,
+	ssa.OpAMD64DIVSD,
+	ssa.OpAMD64DIVSS,
+	ssa.OpAMD64DIVW,
+	ssa.OpAMD64DIVWU,
+	ssa.OpAMD64DUFFCOPY,
+	ssa.OpAMD64DUFFZERO,
+	ssa.OpAMD64HMULB,
+	ssa.OpAMD64HMULBU,
+	ssa.OpAMD64HMULL,
+	ssa.OpAMD64HMULLU,
+	ssa.OpAMD64HMULW,
+	ssa.OpAMD64HMULWU,
+	ssa.OpAMD64InvertFlags,
+	ssa.OpAMD64LEAQ,
+	ssa.OpAMD64LEAQ1,
+	ssa.OpAMD64LEAQ2,
+	ssa.OpAMD64LEAQ4,
+	ssa.OpAMD64LEAQ8,
+	ssa.OpAMD64LoweredGetClosurePtr,
+	ssa.OpAMD64LoweredGetG,
+	ssa.OpAMD64LoweredNilCheck,
+	ssa.OpAMD64MODL,
+	ssa.OpAMD64MODLU,
+	ssa.OpAMD64MODQ,
+	ssa.OpAMD64MODQU,
+	ssa.OpAMD64MODW,
+	ssa.OpAMD64MODWU,
+	ssa.OpAMD64MOVBQSX,
+	ssa.OpAMD64MOVBQSXload,
+	ssa.OpAMD64MOVBQZX,
+	ssa.OpAMD64MOVBload,
+	ssa.OpAMD64MOVBstore,
+	ssa.OpAMD64MOVBstoreconst,
+	ssa.OpAMD64MOVLQSX,
+	ssa.OpAMD64MOVLQZX,
+	ssa.OpAMD64MOVLconst,
+	ssa.OpAMD64MOVLload,
+	ssa.OpAMD64MOVLstore,
+	ssa.OpAMD64MOVLstoreconst,
+	ssa.OpAMD64MOVOconst,
+	ssa.OpAMD64MOVOload,
+	ssa.OpAMD64MOVOstore,
+	ssa.OpAMD64MOVQconst,
+	ssa.OpAMD64MOVQload,
+	ssa.OpAMD64MOVQloadidx8,
+	ssa.OpAMD64MOVQstore,
+	ssa.OpAMD64MOVQstoreconst,
+	ssa.OpAMD64MOVQstoreidx8,
+	ssa.OpAMD64MOVSDconst,
+	ssa.OpAMD64MOVSDload,
+	ssa.OpAMD64MOVSDloadidx8,
+	ssa.OpAMD64MOVSDstore,
+	ssa.OpAMD64MOVSDstoreidx8,
+	ssa.OpAMD64MOVSSconst,
+	ssa.OpAMD64MOVSSload,
+	ssa.OpAMD64MOVSSloadidx4,
+	ssa.OpAMD64MOVSSstore,
+	ssa.OpAMD64MOVSSstoreidx4,
+	ssa.OpAMD64MOVWQSX,
+	ssa.OpAMD64MOVWQZX,
+	ssa.OpAMD64MOVWload,
+	ssa.OpAMD64MOVWstore,
+	ssa.OpAMD64MOVWstoreconst,
+	ssa.OpAMD64MULL,
+	ssa.OpAMD64MULLconst,
+	ssa.OpAMD64MULQ,
+	ssa.OpAMD64MULQconst,
+	ssa.OpAMD64MULSD,
+	ssa.OpAMD64MULSS,
+	ssa.OpAMD64NEGL,
+	ssa.OpAMD64NEGQ,
+	ssa.OpAMD64NOTL,
+	ssa.OpAMD64NOTQ,
+	ssa.OpAMD64ORL,
+	ssa.OpAMD64ORLconst,
+	ssa.OpAMD64ORQ,
+	ssa.OpAMD64ORQconst,
+	ssa.OpAMD64PXOR,
+	ssa.OpAMD64REPMOVSQ,
+	ssa.OpAMD64REPSTOSQ,
+	ssa.OpAMD64ROLBconst,
+	ssa.OpAMD64ROLLconst,
+	ssa.OpAMD64ROLQconst,
+	ssa.OpAMD64ROLWconst,
+	ssa.OpAMD64SARB,
+	ssa.OpAMD64SARBconst,
+	ssa.OpAMD64SARL,
+	ssa.OpAMD64SARLconst,
+	ssa.OpAMD64SARQ,
+	ssa.OpAMD64SARQconst,
+	ssa.OpAMD64SARW,
+	ssa.OpAMD64SARWconst,
+	ssa.OpAMD64SBBLcarrymask,
+	ssa.OpAMD64SBBQcarrymask,
+	ssa.OpAMD64SETA,
+	ssa.OpAMD64SETAE,
+	ssa.OpAMD64SETB,
+	ssa.OpAMD64SETBE,
+	ssa.OpAMD64SETEQ,
+	ssa.OpAMD64SETEQF,
+	ssa.OpAMD64SETG,
+	ssa.OpAMD64SETGE,
+	ssa.OpAMD64SETGEF,
+	ssa.OpAMD64SETGF,
+	ssa.OpAMD64SETL,
+	ssa.OpAMD64SETLE,
+	ssa.OpAMD64SETNAN,
+	ssa.OpAMD64SETNE,
+	ssa.OpAMD64SETNEF,
+	ssa.OpAMD64SETORD,
+	ssa.OpAMD64SHLL,
+	ssa.OpAMD64SHLLconst,
+	ssa.OpAMD64SHLQ,
+	ssa.OpAMD64SHLQconst,
+	ssa.OpAMD64SHRB,
+	ssa.OpAMD64SHRBconst,
+	ssa.OpAMD64SHRL,
+	ssa.OpAMD64SHRLconst,
+	ssa.OpAMD64SHRQ,
+	ssa.OpAMD64SHRQconst,
+	ssa.OpAMD64SHRW,
+	ssa.OpAMD64SHRWconst,
+	ssa.OpAMD64SQRTSD,
+	ssa.OpAMD64SUBL,
+	ssa.OpAMD64SUBLconst,
+	ssa.OpAMD64SUBQ,
+	ssa.OpAMD64SUBQconst,
+	ssa.OpAMD64SUBSD,
+	ssa.OpAMD64SUBSS,
+	ssa.OpAMD64TESTB,
+	ssa.OpAMD64TESTBconst,
+	ssa.OpAMD64TESTL,
+	ssa.OpAMD64TESTLconst,
+	ssa.OpAMD64TESTQ,
+	ssa.OpAMD64TESTQconst,
+	ssa.OpAMD64TESTW,
+	ssa.OpAMD64TESTWconst,
+	ssa.OpAMD64UCOMISD,
+	ssa.OpAMD64UCOMISS,
+	ssa.OpAMD64XORL,
+	ssa.OpAMD64XORLconst,
+	ssa.OpAMD64XORQ,
+	ssa.OpAMD64XORQconst,
+}
+
+// ssaOps maps the names of the ops to the ops, an op is named as in SSA
+// dumps, "Add64" or "PXOR", or as its Go constant without the "Op",
+// "AMD64PXOR".
+var ssaOps = opNames()
+
+func opNames() map[string]ssa.Op {
+	names := map[string]ssa.Op{}
+	for _, op := range genericOps {
+		names[op.String()] = op
+	}
+	for _, op := range amd64Ops {
+		names[op.String()] = op
+		names["AMD64"+op.String()] = op
+	}
+	return names
+}
+
+// LookupOp returns the op named name, as in an SSA dump.
+func LookupOp(name string) (ssa.Op, bool) {
+	op, ok := ssaOps[name]
+	return op, ok
+}
+
+// genericBlockKinds are the machine independent block kinds, listed as
+// the genericOps are.
+var genericBlockKinds = []ssa.BlockKind{
+	ssa.BlockExit,
+	ssa.BlockIf,
+	ssa.BlockPlain,
+	ssa.BlockRet,
+	ssa.BlockRetJmp,
+}
+
+// amd64BlockKinds are the machine block kinds genBlock assembles,
+// listed as the amd64Ops are.
+var amd64BlockKinds = []ssa.BlockKind{
+	ssa.BlockAMD64EQ,
+	ssa.BlockAMD64EQF,
+	ssa.BlockAMD64GE,
+	ssa.BlockAMD64GT,
+	ssa.BlockAMD64LE,
+	ssa.BlockAMD64LT,
+	ssa.BlockAMD64NAN,
+	ssa.BlockAMD64NE,
+	ssa.BlockAMD64NEF,
+	ssa.BlockAMD64ORD,
+	ssa.BlockAMD64UGE,
+	ssa.BlockAMD64UGT,
+	ssa.BlockAMD64ULE,
+	ssa.BlockAMD64ULT,
+}
+
+// ssaBlockKinds maps the names of the block kinds to the kinds, named
+// like the ops, "Plain", "EQ" or "AMD64EQ".
+var ssaBlockKinds = blockKindNames()
+
+func blockKindNames() map[string]ssa.BlockKind {
+	names := map[string]ssa.BlockKind{}
+	for _, kind := range genericBlockKinds {
+		names[kind.String()] = kind
+	}
+	for _, kind := range amd64BlockKinds {
+		names[kind.String()] = kind
+		names["AMD64"+kind.String()] = kind
+	}
+	return names
+}
+
+// LookupBlockKind returns the block kind named name, as in an SSA dump.
+func LookupBlockKind(name string) (ssa.BlockKind, bool) {
+	kind, ok := ssaBlockKinds[name]
+	return kind, ok
+}
@@ -7,21 +7,17 @@ package main
 //go:generate gir -f testdata/test4.gir -o testdata/test4_amd64.s -proto testdata/test4_proto.go
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"strings"
 
-	"github.com/bjwbell/gir/codegen"
+	"github.com/bjwbell/gir/compile"
 	"github.com/bjwbell/gir/config"
 	"github.com/bjwbell/gir/ctx"
-	"github.com/bjwbell/gir/parse"
 	"github.com/bjwbell/gir/scan"
-	"github.com/bjwbell/gir/token"
 	"github.com/bjwbell/gir/value"
 )
 
@@ -50,6 +46,10 @@ func main() {
 	var f = flag.String("f", "", "input *.gir file ")
 	var o = flag.String("o", "", "output *.s file ")
 	var proto = flag.String("proto", "", "output *.go prototype file")
+	var ssaDump = flag.Bool("ssa", false, "the input is a GOSSAFUNC SSA dump")
+	var pkgName = flag.String("pkg", "main", "package of the prototypes of an SSA dump")
+	var girOut = flag.String("gir", "", "output *.gir file of the compiled functions")
+	var pass = flag.String("pass", "", "pass of the SSA backend after which -gir prints the functions")
 	flag.Parse()
 
 	file := ""
@@ -67,66 +67,36 @@ func main() {
 	if *proto != "" {
 		protofile = *proto
 	}
-	var fd io.Reader
-	var err error
-	fd, err = os.Open(file)
+	fd, err := os.Open(file)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "gir: %s\n", err)
 		os.Exit(1)
 	}
-	var fd2 io.Reader
-	var err2 error
-	fd2, err2 = os.Open(file)
-	if err2 != nil {
-		fmt.Fprintf(os.Stderr, "gir: %s\n", err2)
+	res, err := compile.Compile(file, fd, compile.Options{
+		SSA:  *ssaDump,
+		Pkg:  *pkgName,
+		Gir:  *girOut != "",
+		Pass: *pass,
+	})
+	fd.Close()
+	if err != nil {
+		scan.PrintError(os.Stderr, err)
 		os.Exit(1)
 	}
-	scanner1 := scan.New(context, file, bufio.NewReader(fd))
-	scanner2 := scan.New(context, file, bufio.NewReader(fd2))
-
-	fmt.Println("Tokens: ")
-	for tok := scanner1.Next(); tok.Type != token.EOF; tok = scanner1.Next() {
-		fmt.Println(tok, "type ", tok.Type)
-	}
-	parser := parse.NewParser(file, scanner2, context)
-	fileDecl := parser.ParseFile()
-	pkg := fileDecl.PkgName
-	fmt.Println("tree(exprs): ", parse.Tree(fileDecl))
-	asm := ""
-	protos := ""
-	for _, fnDecl := range fileDecl.Decls {
-		ssafn, ok := codegen.BuildSSA(&fnDecl, fileDecl.PkgName, false)
-		if ssafn == nil || !ok {
-			fmt.Println("Error building SSA form")
-			return
-		} else {
-			fmt.Println("ssa:\n", ssafn)
-			var ok bool
-			asm, ok = codegen.GenAsm(ssafn)
-			if !ok {
-				fmt.Println("Error generating assembly")
-			}
-
-			if fnProto, ok := codegen.GenGoProto(ssafn); ok {
-				protos += fnProto
-			} else {
-				fmt.Println("Error generating Go proto file")
-			}
-
+	if outfile != "" {
+		err := ioutil.WriteFile(outfile, []byte(res.Asm()), 0644)
+		if err != nil {
+			panic(err)
 		}
 	}
-
-	if outfile != "" {
-		err = ioutil.WriteFile(outfile, []byte(asm), 0644)
+	if *girOut != "" {
+		err := ioutil.WriteFile(*girOut, []byte(res.Gir()), 0644)
 		if err != nil {
 			panic(err)
 		}
 	}
 	if protofile != "" {
-		protoTxt := "// +build amd64\n\n"
-		protoTxt += fmt.Sprintf("package %s\n", pkg)
-		protoTxt += protos
-		err = ioutil.WriteFile(protofile, []byte(protoTxt), 0644)
+		err := ioutil.WriteFile(protofile, []byte(res.Proto()), 0644)
 		if err != nil {
 			panic(err)
 		}
@@ -4,16 +4,33 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"go/types"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/bjwbell/cmd/obj/x86"
 	"github.com/bjwbell/gir/codegen"
+	"github.com/bjwbell/gir/compile"
 	"github.com/bjwbell/gir/config"
 	"github.com/bjwbell/gir/ctx"
+	"github.com/bjwbell/gir/dump"
+	"github.com/bjwbell/gir/gimporter"
+	"github.com/bjwbell/gir/gst"
 	"github.com/bjwbell/gir/parse"
 	"github.com/bjwbell/gir/scan"
 	"github.com/bjwbell/gir/testdata"
+	"github.com/bjwbell/gir/token"
 	"github.com/bjwbell/gir/value"
 )
 
@@ -26,11 +43,14 @@ func runTest(t *testing.T, filename string) {
 	}
 	scanner := scan.New(context, filename, bufio.NewReader(fd))
 	parser := parse.NewParser(filename, scanner, context)
-	fileDecl := parser.ParseFile()
+	fileDecl, err := parser.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
 	for _, fnDecl := range fileDecl.Decls {
-		ssafn, ok := codegen.BuildSSA(&fnDecl, fileDecl.PkgName, false)
-		if ssafn == nil || !ok {
-			t.Fatalf("gir: Error building SSA form")
+		ssafn, err := codegen.Build(&fnDecl, fileDecl.PkgName, false)
+		if err != nil {
+			t.Fatalf("gir: %v", err)
 			return
 		} else {
 			t.Log("ssa:\n", ssafn)
@@ -53,6 +73,1470 @@ func TestT3(t *testing.T) { testdata.T3() }
 // TestT4 tests calling generated *_amd64.s function
 func TestT4(t *testing.T) { testdata.T4() }
 
+// parseFile lexes and parses the gir file, filename, in testdata
+func parseFile(t *testing.T, filename string) *gst.File {
+	context := ctx.NewContext(&conf)
+	file := filepath.Join("testdata", filename)
+	fd, err := os.Open(file)
+	if err != nil {
+		t.Fatalf("gir: %s\n", err)
+	}
+	defer fd.Close()
+	scanner := scan.New(context, file, bufio.NewReader(fd))
+	parser := parse.NewParser(file, scanner, context)
+	fileDecl, err := parser.ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fileDecl
+}
+
+// inspectFile type checks the functions of the gir file, filename, in
+// testdata and calls visit with each node of their bodies, the function
+// and its type information
+func inspectFile(t *testing.T, filename string, visit func(n ast.Node, fn *types.Func, info *types.Info)) {
+	fileDecl := parseFile(t, filename)
+	for i := range fileDecl.Decls {
+		decl, err := gimporter.FuncDecl(&fileDecl.Decls[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn, info, err := gimporter.Check(fileDecl.PkgName, decl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(decl.Body, func(n ast.Node) bool {
+			if n != nil {
+				visit(n, fn, info)
+			}
+			return true
+		})
+	}
+}
+
+// checkAsm compiles the gir file, filename, in testdata and checks that
+// the assembly of each function of expected matches its patterns, with
+// $r a general purpose register and $x an SSE register. It returns the
+// compiled functions.
+func checkAsm(t *testing.T, filename string, expected map[string][]string) []*compile.Func {
+	src, err := ioutil.ReadFile(filepath.Join("testdata", filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := compile.Compile(filename, bytes.NewReader(src), compile.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	regs := strings.NewReplacer(
+		"$r", "(AX|CX|DX|BX|BP|SI|DI|R8|R9|R10|R11|R12|R13|R14|R15)",
+		"$x", "(X[0-9]|X1[0-5])")
+	compiled := map[string]bool{}
+	for _, fn := range res.Funcs {
+		compiled[fn.Name] = true
+		for _, pattern := range expected[fn.Name] {
+			if !regexp.MustCompile("(?m)" + regs.Replace(pattern)).MatchString(fn.Asm) {
+				t.Errorf("%v: expected %v in:\n%v", fn.Name, pattern, fn.Asm)
+			}
+		}
+	}
+	for name := range expected {
+		if !compiled[name] {
+			t.Errorf("%v wasn't compiled", name)
+		}
+	}
+	return res.Funcs
+}
+
+// TestParams tests typed parameters and results in function signatures
+func TestParams(t *testing.T) {
+	fileDecl := parseFile(t, "params.gir")
+	expected := []string{
+		"func(x int64, y int64) int64",
+		"func(x int32, n int32) (r int32)",
+	}
+	if len(fileDecl.Decls) != len(expected) {
+		t.Fatalf("expected %v functions, got %v", len(expected), len(fileDecl.Decls))
+	}
+	for i, fnDecl := range fileDecl.Decls {
+		fn, err := gimporter.ParseFuncDecl(&fnDecl)
+		if err != nil {
+			t.Fatalf("gir: %v", err)
+		}
+		if sig := fn.Type().String(); sig != expected[i] {
+			t.Errorf("%v: expected signature %q, got %q", fnDecl.Name, expected[i], sig)
+		}
+	}
+}
+
+// TestBlock tests functions bodies with multiple statements
+func TestBlock(t *testing.T) {
+	fileDecl := parseFile(t, "block.gir")
+	expected := []int{4, 1}
+	if len(fileDecl.Decls) != len(expected) {
+		t.Fatalf("expected %v functions, got %v", len(expected), len(fileDecl.Decls))
+	}
+	for i, fnDecl := range fileDecl.Decls {
+		if n := len(fnDecl.Body.List); n != expected[i] {
+			t.Errorf("%v: expected %v statements, got %v", fnDecl.Name, expected[i], n)
+		}
+	}
+	if _, ok := fileDecl.Decls[0].Body.List[3].(*gst.RetStmt); !ok {
+		t.Errorf("block: expected return as last statement")
+	}
+}
+
+// TestAssign tests assignment statements
+func TestAssign(t *testing.T) {
+	fileDecl := parseFile(t, "assign.gir")
+	fnDecl := fileDecl.Decls[0]
+	expected := []string{"y", "z", "z"}
+	for i, name := range expected {
+		assign, ok := fnDecl.Body.List[i].(*gst.AssignStmt)
+		if !ok {
+			t.Fatalf("expected assignment, got %T", fnDecl.Body.List[i])
+		}
+		if assign.Lhs.Name != name {
+			t.Errorf("expected assignment to %v, got %v", name, assign.Lhs.Name)
+		}
+	}
+	decl, err := gimporter.FuncDecl(&fnDecl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gimporter.Check(fileDecl.PkgName, decl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGoto tests labeled blocks and goto
+func TestGoto(t *testing.T) {
+	fileDecl := parseFile(t, "goto.gir")
+	fnDecl := fileDecl.Decls[0]
+	list := fnDecl.Body.List
+	if len(list) != 5 {
+		t.Fatalf("expected 5 statements, got %v", len(list))
+	}
+	for _, i := range []int{1, 4} {
+		if _, ok := list[i].(*gst.GotoStmt); !ok {
+			t.Errorf("expected goto, got %T", list[i])
+		}
+	}
+	for i, label := range map[int]string{2: "b2", 3: "b3"} {
+		labeled, ok := list[i].(*gst.LabeledStmt)
+		if !ok {
+			t.Fatalf("expected label, got %T", list[i])
+		}
+		if labeled.Label.Name != label {
+			t.Errorf("expected label %v, got %v", label, labeled.Label.Name)
+		}
+	}
+	decl, err := gimporter.FuncDecl(&fnDecl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gimporter.Check(fileDecl.PkgName, decl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestIf tests conditional branches and comparisons
+func TestIf(t *testing.T) {
+	fileDecl := parseFile(t, "if.gir")
+	fnDecl := fileDecl.Decls[0]
+	list := fnDecl.Body.List
+	if tree := parse.Tree(list[0]); tree != "(<var c> = ((<var x> + <int (1)>) <= <var max>))" {
+		t.Errorf("unexpected comparison %v", tree)
+	}
+	ifStmt, ok := list[1].(*gst.IfStmt)
+	if !ok {
+		t.Fatalf("expected if, got %T", list[1])
+	}
+	if ifStmt.Yes.Name != "b2" || ifStmt.No.Name != "b3" {
+		t.Errorf("expected if c goto b2 else b3, got %v", parse.Tree(ifStmt))
+	}
+	decl, err := gimporter.FuncDecl(&fnDecl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, info, err := gimporter.Check(fileDecl.PkgName, decl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// after the declaration of c
+	cond := decl.Body.List[2].(*ast.IfStmt).Cond
+	if typ := info.TypeOf(cond).String(); typ != "bool" {
+		t.Errorf("expected bool condition, got %v", typ)
+	}
+}
+
+// TestPos tests the positions of tokens and gst nodes
+func TestPos(t *testing.T) {
+	fileDecl := parseFile(t, "if.gir")
+	file := filepath.Join("testdata", "if.gir")
+	fnDecl := fileDecl.Decls[0]
+	assign := fnDecl.Body.List[0].(*gst.AssignStmt)
+	labeled := fnDecl.Body.List[2].(*gst.LabeledStmt)
+	for _, test := range []struct {
+		node      string
+		pos       token.Pos
+		line, col int
+	}{
+		{"package", fileDecl.Package, 1, 1},
+		{"func", fnDecl.Func, 3, 1},
+		{"clamp", fnDecl.NamePos, 3, 6},
+		{"max", fnDecl.Params[1].Pos, 3, 21},
+		{"r", fnDecl.Results[0].Pos, 3, 33},
+		{"{", fnDecl.Body.Pos(), 3, 42},
+		{"c =", assign.Pos(), 4, 6},
+		{"=", assign.Assign, 4, 8},
+		{"x + 1", assign.Rhs.(*gst.BinaryExpr).X.(*gst.BinaryExpr).Pos(), 4, 10},
+		{"<=", assign.Rhs.(*gst.BinaryExpr).OpPos, 4, 16},
+		{"if", fnDecl.Body.List[1].Pos(), 5, 6},
+		{"b3", fnDecl.Body.List[1].(*gst.IfStmt).No.Pos(), 5, 24},
+		{"b2:", labeled.Pos(), 6, 1},
+		{"x", labeled.Stmt.(*gst.AssignStmt).Rhs.(*gst.Ident).Pos(), 7, 10},
+	} {
+		if test.pos.File != file || test.pos.Line != test.line || test.pos.Col != test.col {
+			t.Errorf("%v: expected %v:%v:%v, got %v", test.node, file, test.line, test.col, test.pos)
+		}
+	}
+	// a comment ends its line
+	context := ctx.NewContext(&conf)
+	scanner := scan.New(context, "pos.gir", bufio.NewReader(strings.NewReader("x = 1 # one\n\ty")))
+	var last token.Token
+	for tok := scanner.Next(); tok.Type != token.EOF; tok = scanner.Next() {
+		last = tok
+	}
+	if last.Text != "y" || last.Pos.String() != "pos.gir:2:2" {
+		t.Errorf("expected y at pos.gir:2:2, got %v at %v", last, last.Pos)
+	}
+}
+
+// TestParseErrors tests that the parser reports every error with its
+// position and keeps the statements and functions without errors
+func TestParseErrors(t *testing.T) {
+	src := `package errs
+
+func f(x int) (r int) {
+	r = x +
+	r = x
+	goto
+	return
+}
+
+func (x int) {
+	return
+}
+
+func g(x int, y) (r int) {
+	return
+}
+
+func h(x int) (r int) {
+	r = x )
+	return
+}
+`
+	context := ctx.NewContext(&conf)
+	scanner := scan.New(context, "errs.gir", bufio.NewReader(strings.NewReader(src)))
+	fileDecl, err := parse.NewParser("errs.gir", scanner, context).ParseFile()
+	list, ok := err.(scan.ErrorList)
+	if !ok {
+		t.Fatalf("expected a scan.ErrorList, got %v", err)
+	}
+	for _, e := range list {
+		t.Log(e)
+	}
+	expected := []string{
+		"errs.gir:4:",
+		"errs.gir:6:",
+		"errs.gir:10:6",
+		"errs.gir:14:15",
+		"errs.gir:19:8",
+	}
+	if len(list) != len(expected) {
+		t.Fatalf("expected %v errors, got %v: %v", len(expected), len(list), err)
+	}
+	for i, e := range list {
+		if !strings.HasPrefix(e.Error(), expected[i]) {
+			t.Errorf("error %v: expected position %v, got %v", i, expected[i], e)
+		}
+	}
+	if fileDecl.PkgName != "errs" {
+		t.Errorf("expected package errs, got %v", fileDecl.PkgName)
+	}
+	var names []string
+	for _, decl := range fileDecl.Decls {
+		names = append(names, fmt.Sprintf("%v:%v", decl.Name, len(decl.Body.List)))
+	}
+	if got := strings.Join(names, " "); got != "f:2 h:1" {
+		t.Errorf("expected functions f:2 h:1, got %v", got)
+	}
+}
+
+// TestCompile tests that Compile returns the errors of every stage as
+// diagnostics instead of panicking
+func TestCompile(t *testing.T) {
+	src := `package errs
+
+func f(x int) (r int) {
+	r = y
+	return
+}
+
+func g(x int) (r int) {
+	r = x )
+	return
+}
+`
+	res, err := compile.Compile("errs.gir", strings.NewReader(src), compile.Options{})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, e := range res.Diagnostics {
+		t.Log(e)
+	}
+	if !reflect.DeepEqual(err, res.Diagnostics) {
+		t.Errorf("expected the diagnostics as the error, got %v", err)
+	}
+	expected := []string{
+		"errs.gir:9:8: unexpected RightParen",
+		"errs.gir:4:6: f: undefined: y",
+	}
+	if len(res.Diagnostics) < len(expected) {
+		t.Fatalf("expected %v diagnostics, got %v", len(expected), len(res.Diagnostics))
+	}
+	for i, e := range expected {
+		if got := res.Diagnostics[i].Error(); !strings.HasPrefix(got, e) {
+			t.Errorf("expected %v, got %v", e, got)
+		}
+	}
+	if res.Pkg != "errs" {
+		t.Errorf("expected package errs, got %v", res.Pkg)
+	}
+}
+
+// TestCompileFiles tests that every function is in the assembly and
+// prototype files once, after a single preamble
+func TestCompileFiles(t *testing.T) {
+	res := &compile.Result{
+		Pkg: "testdata",
+		Funcs: []*compile.Func{
+			{Name: "test1", Asm: "TEXT ·test1(SB),$0-0\nRET\n", Proto: "func test1()\n"},
+			{Name: "test2", Asm: "TEXT ·test2(SB),$0-0\nRET\n", Proto: "func test2()\n"},
+		},
+	}
+	asm := codegen.Preamble() + "TEXT ·test1(SB),$0-0\nRET\n\nTEXT ·test2(SB),$0-0\nRET\n"
+	if got := res.Asm(); got != asm {
+		t.Errorf("expected assembly:\n%v\ngot:\n%v", asm, got)
+	}
+	proto := "// +build amd64\n\npackage testdata\nfunc test1()\nfunc test2()\n"
+	if got := res.Proto(); got != proto {
+		t.Errorf("expected prototypes:\n%v\ngot:\n%v", proto, got)
+	}
+	// the checked in output of test.gir is the same
+	for file, expected := range map[string]string{"test_amd64.s": asm, "test_proto.go": proto} {
+		b, err := ioutil.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != expected {
+			t.Errorf("%v: expected:\n%v\ngot:\n%s", file, expected, b)
+		}
+	}
+}
+
+// TestArgsSize tests the argsize of TEXT directives and the offsets of
+// the params and results, the results start at a word boundary after
+// the params and the argsize is the end of the last one, as go vet
+// expects
+func TestArgsSize(t *testing.T) {
+	for _, test := range []struct {
+		sig             string
+		params, results []int64
+		size            int64
+	}{
+		{"func()", nil, nil, 0},
+		{"func(x int64, y int64) int64", []int64{0, 8}, []int64{16}, 24},
+		{"func(x int32, n int32) (r int32)", []int64{0, 4}, []int64{8}, 12},
+		{"func(a int8, b int64) (r bool)", []int64{0, 8}, []int64{16}, 17},
+		{"func(a, b int8, c int16) (r int8, s int32)", []int64{0, 1, 2}, []int64{8, 12}, 16},
+		{"func(p *int, f float32)", []int64{0, 8}, nil, 12},
+	} {
+		tv, err := types.Eval(gotoken.NewFileSet(), nil, gotoken.NoPos, test.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := tv.Type.(*types.Signature)
+		if size := codegen.ArgsSize(sig); size != test.size {
+			t.Errorf("%v: expected argsize %v, got %v", test.sig, test.size, size)
+		}
+		params, results, _ := codegen.ArgOffsets(sig)
+		if !reflect.DeepEqual(params, test.params) || !reflect.DeepEqual(results, test.results) {
+			t.Errorf("%v: expected offsets %v %v, got %v %v", test.sig, test.params, test.results, params, results)
+		}
+	}
+	// args are addressed by name for go vet
+	for _, test := range []struct {
+		addr     codegen.Addr
+		expected string
+	}{
+		{codegen.Addr{Type: codegen.TYPE_MEM, Name: codegen.NAME_PARAM, Sym: &codegen.LSym{Name: "x"}}, "x+0(FP)"},
+		{codegen.Addr{Type: codegen.TYPE_MEM, Name: codegen.NAME_PARAM, Sym: &codegen.LSym{Name: "ret"}, Offset: 16}, "ret+16(FP)"},
+		{codegen.Addr{Type: codegen.TYPE_ADDR, Name: codegen.NAME_PARAM, Sym: &codegen.LSym{Name: "y"}, Offset: 8}, "$y+8(FP)"},
+	} {
+		if got := codegen.Dconv(nil, &test.addr); got != test.expected {
+			t.Errorf("expected %v, got %v", test.expected, got)
+		}
+	}
+	// the operands and the argsize agree, as go vet checks
+	src := "package args\n\nfunc f(x int32) (r int32) {\n\tr = x\n\treturn\n}\n"
+	res, err := compile.Compile("args.gir", strings.NewReader(src), compile.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"TEXT ·f(SB),$0-12\n", "x+0(FP)", "r+8(FP)"} {
+		if !strings.Contains(res.Funcs[0].Asm, expected) {
+			t.Errorf("expected %v in:\n%v", expected, res.Funcs[0].Asm)
+		}
+	}
+}
+
+// TestLabels tests that blocks are labeled and branches jump to the
+// labels
+func TestLabels(t *testing.T) {
+	label := &codegen.Prog{As: codegen.ALABEL}
+	label.From = codegen.Addr{Type: codegen.TYPE_BRANCH, Sym: &codegen.LSym{Name: "b3"}}
+	jmp := &codegen.Prog{As: codegen.AJMP}
+	jmp.To = codegen.Addr{Type: codegen.TYPE_BRANCH, Val: label}
+	ret := &codegen.Prog{As: codegen.ARET}
+	expected := "JMP\tb3\nb3:\nRET\n"
+	if asm := codegen.Assemble([]*codegen.Prog{jmp, label, ret}); asm != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, asm)
+	}
+}
+
+// TestRegisterNames tests that registers print with their Plan 9 names
+func TestRegisterNames(t *testing.T) {
+	names := map[int]string{
+		x86.REG_AX: "AX", x86.REG_CX: "CX", x86.REG_DX: "DX", x86.REG_BX: "BX",
+		x86.REG_SP: "SP", x86.REG_BP: "BP", x86.REG_SI: "SI", x86.REG_DI: "DI",
+		x86.REG_R8: "R8", x86.REG_R9: "R9", x86.REG_R10: "R10", x86.REG_R11: "R11",
+		x86.REG_R12: "R12", x86.REG_R13: "R13", x86.REG_R14: "R14", x86.REG_R15: "R15",
+		x86.REG_X0: "X0", x86.REG_X1: "X1", x86.REG_X2: "X2", x86.REG_X3: "X3",
+		x86.REG_X4: "X4", x86.REG_X5: "X5", x86.REG_X6: "X6", x86.REG_X7: "X7",
+		x86.REG_X8: "X8", x86.REG_X9: "X9", x86.REG_X10: "X10", x86.REG_X11: "X11",
+		x86.REG_X12: "X12", x86.REG_X13: "X13", x86.REG_X14: "X14", x86.REG_X15: "X15",
+		x86.REG_AH: "AH",
+	}
+	for reg, name := range names {
+		if got := codegen.Rconv(reg); got != name {
+			t.Errorf("expected register %v, got %v", name, got)
+		}
+	}
+	for _, test := range []struct {
+		addr     codegen.Addr
+		expected string
+	}{
+		{codegen.Addr{Type: codegen.TYPE_REG, Reg: x86.REG_R11}, "R11"},
+		{codegen.Addr{Type: codegen.TYPE_MEM, Reg: x86.REG_SI, Offset: 8}, "8(SI)"},
+		{codegen.Addr{Type: codegen.TYPE_MEM, Reg: x86.REG_AX, Index: x86.REG_R9, Scale: 8}, "(AX)(R9*8)"},
+	} {
+		if got := codegen.Dconv(nil, &test.addr); got != test.expected {
+			t.Errorf("expected %v, got %v", test.expected, got)
+		}
+	}
+}
+
+// TestGenValueRegs tests the register operands of the instructions of
+// each op of regs.gir, the allocated registers vary
+func TestGenValueRegs(t *testing.T) {
+	expected := map[string][]string{
+		"addq":       {`^MOVQ\tx\+0\(FP\), $r$`, `^LEAQ\t\($r\)\($r\*1\), $r$`, `^MOVQ\t$r, r\+16\(FP\)$`},
+		"addl":       {`^LEAL\t\($r\)\($r\*1\), $r$`},
+		"andq":       {`^ANDQ\t$r, $r$`},
+		"mulsd":      {`^MULSD\t$x, $x$`},
+		"pxor":       {`^PXOR\t$x, $x$`},
+		"subq":       {`^SUBQ\t$r, $r$`},
+		"divsd":      {`^DIVSD\t$x, $x$`},
+		"divqu":      {`^XORQ\tDX, DX$`, `^DIVQ\t$r$`},
+		"hmull":      {`^IMULL\t$r$`},
+		"shlq":       {`^SHLQ\tCX, $r$`},
+		"addqconst":  {`^LEAQ\t8\($r\), $r$`},
+		"mulqconst":  {`^IMULQ\t\$3, $r$`},
+		"andqconst":  {`^ANDQ\t\$255, $r$`},
+		"carrymask":  {`^CMPQ\t$r, $r$`, `^SBBQ\t$r, $r$`},
+		"leaq8":      {`^LEAQ\t\($r\)\($r\*8\), $r$`},
+		"setl":       {`^CMPQ\t$r, $r$`, `^SETLT\t$r$`},
+		"setgf":      {`^UCOMISD\t$x, $x$`, `^SETHI\t$r$`},
+		"seteq":      {`^CMPQ\t$r, \$7$`, `^SETEQ\t$r$`},
+		"setnef":     {`^SETNE\t$r$`, `^SETPS\tAX$`, `^ORQ\tAX, $r$`},
+		"movqconst":  {`^MOVQ\t\$42, $r$`},
+		"movsdconst": {`^MOVSD\t\$\(1\.0\), $x$`},
+		"load":       {`^MOVQ\t\($r\), $r$`, `^MOVQ\t$r, (\($r\)|r\+8\(FP\))$`},
+		"storeconst": {`^MOVQ\t\$7, \($r\)$`},
+		"movlqsx":    {`^MOVLQSX\t$r, $r$`},
+		"cvtsq2sd":   {`^CVTSQ2SD\t$r, $x$`},
+		"negq":       {`^NEGQ\t$r$`},
+		"sqrtsd":     {`^SQRTSD\t$x, $x$`},
+	}
+	for _, fn := range checkAsm(t, "regs.gir", expected) {
+		if strings.Contains(fn.Asm, "R???") || strings.Contains(fn.Asm, "NONE") {
+			t.Errorf("%v: unnamed register in:\n%v", fn.Name, fn.Asm)
+		}
+	}
+}
+
+// toolchainCalls are the calls of the functions of testdata with known
+// inputs and their expected results
+var toolchainCalls = map[string][]struct{ call, want string }{
+	"seven":      {{"seven()", "7"}},
+	"sum":        {{"sum(3)", "6"}, {"sum(0)", "0"}},
+	"max":        {{"max(3, 5)", "5"}, {"max(5, 3)", "5"}},
+	"clamp":      {{"clamp(3, 10)", "3"}, {"clamp(10, 5)", "5"}},
+	"jump":       {{"jump(1)", "3"}},
+	"mask":       {{"mask(10, 7)", "16"}},
+	"pxor":       {{"pxor(1.5, 1.5)", "0"}},
+	"addq":       {{"addq(3, 4)", "7"}},
+	"addl":       {{"addl(3, -4)", "-1"}},
+	"andq":       {{"andq(12, 10)", "8"}},
+	"mulsd":      {{"mulsd(1.5, 2)", "3"}},
+	"subq":       {{"subq(10, 3)", "7"}},
+	"divsd":      {{"divsd(7, 2)", "3.5"}},
+	"divqu":      {{"divqu(7, 2)", "3"}},
+	"hmull":      {{"hmull(1<<30, 8)", "2"}},
+	"shlq":       {{"shlq(1, 4)", "16"}},
+	"addqconst":  {{"addqconst(1)", "9"}},
+	"mulqconst":  {{"mulqconst(5)", "15"}},
+	"andqconst":  {{"andqconst(511)", "255"}},
+	"carrymask":  {{"carrymask(1, 2)", "-1"}, {"carrymask(2, 1)", "0"}},
+	"leaq8":      {{"leaq8(1, 2)", "17"}},
+	"setl":       {{"setl(1, 2)", "true"}, {"setl(2, 1)", "false"}},
+	"setgf":      {{"setgf(2, 1)", "true"}, {"setgf(1, 2)", "false"}},
+	"seteq":      {{"seteq(7)", "true"}, {"seteq(8)", "false"}},
+	"setnef":     {{"setnef(1, 2)", "true"}, {"setnef(1, 1)", "false"}},
+	"movqconst":  {{"movqconst()", "42"}},
+	"movsdconst": {{"movsdconst()", "1"}},
+	"load":       {{"func() int64 { x := int64(5); return load(&x) }()", "5"}},
+	"storeconst": {{"func() int64 { var x int64; storeconst(&x); return x }()", "7"}},
+	"movlqsx":    {{"movlqsx(-1)", "-1"}},
+	"cvtsq2sd":   {{"cvtsq2sd(3)", "3"}},
+	"negq":       {{"negq(5)", "-5"}},
+	"sqrtsd":     {{"sqrtsd(16)", "4"}},
+	"sub":        {{"sub(3, 10)", "-7"}},
+	"add8":       {{"add8(127, 1)", "-128"}},
+	"mul32":      {{"mul32(-3, 5)", "-15"}, {"mul32(1<<16, 1<<16)", "0"}},
+	"quo":        {{"quo(-7, 2)", "-3"}, {"quo(-1<<63, -1)", "-1 << 63"}},
+	"rem":        {{"rem(-7, 2)", "-1"}, {"rem(7, -1)", "0"}},
+	"quo16":      {{"quo16(100, -7)", "-14"}, {"quo16(-1<<15, -1)", "-1 << 15"}},
+	"quou":       {{"quou(1<<32-1, 2)", "1<<31 - 1"}},
+	"remu8":      {{"remu8(200, 7)", "4"}},
+	"and8":       {{"and8(-1, 5)", "5"}},
+	"or16":       {{"or16(0xf0, 0x0f)", "0xff"}},
+	"xor":        {{"xor(0xff, 0x0f)", "0xf0"}},
+	"andNot":     {{"andNot(0xff, 0x0f)", "0xf0"}},
+	"shl":        {{"shl(3, 2)", "12"}, {"shl(1, 64)", "0"}},
+	"shr":        {{"shr(-8, 1)", "-4"}, {"shr(-8, 100)", "-1"}},
+	"shru":       {{"shru(1<<31, 31)", "1"}, {"shru(1<<31, 32)", "0"}},
+	"shl8":       {{"shl8(0x81, 1)", "2"}, {"shl8(1, 8)", "0"}},
+	"sar16":      {{"sar16(-256, 4)", "-16"}, {"sar16(-256, 16)", "-1"}},
+	"shlconst":   {{"shlconst(1)", "0"}},
+	"shrconst":   {{"shrconst(0x100)", "0x10"}},
+	"rotl64":     {{"rotl64(1<<63|1, 1)", "3"}, {"rotl64(1, 65)", "2"}, {"rotl64(5, 0)", "5"}},
+	"rotr32":     {{"rotr32(1, 1)", "1 << 31"}, {"rotr32(3, -1)", "6"}},
+	"rotl16c":    {{"rotl16c(0x1234)", "0x2341"}},
+	"rotr8c":     {{"rotr8c(1)", "0x20"}},
+	"trunc8":     {{"trunc8(0x1ff)", "-1"}},
+	"sext":       {{"sext(-3)", "-3"}},
+	"zext":       {{"zext(0xffff)", "0xffff"}},
+	"u2s":        {{"u2s(1 << 31)", "-1 << 31"}},
+	"i2f":        {{"i2f(-7)", "-7"}},
+	"u8tof32":    {{"u8tof32(200)", "200"}},
+	"f2i16":      {{"f2i16(-3.9)", "-3"}},
+	"f32tou32":   {{"f32tou32(4e9)", "4e9"}},
+	"u64tof":     {{"u64tof(1<<63 + 1025)", "1<<63 + 2048"}, {"u64tof(1<<64 - 1)", "1 << 64"}, {"u64tof(3)", "3"}},
+	"u64tof32":   {{"u64tof32(1<<64 - 1)", "1 << 64"}, {"u64tof32(5)", "5"}},
+	"f2u64":      {{"f2u64(1<<63 + 4096)", "1<<63 + 4096"}, {"f2u64(5.5)", "5"}},
+	"f32tof64":   {{"f32tof64(0.5)", "0.5"}},
+	"f64tof32":   {{"f64tof32(1.5)", "1.5"}},
+	"mixed":      {{"mixed(-2, 0x10003)", "-131075"}},
+	"fadd":       {{"fadd(1, 2)", "6"}},
+	"fsub32":     {{"fsub32(1.5, 0.25)", "1.25"}},
+	"fhalf32":    {{"fhalf32(3)", "1.5"}},
+	"fdiv":       {{"fdiv(1, 4)", "0.25"}},
+	"fneg":       {{"fneg(2)", "-2"}, {"fneg(-0.5)", "0.5"}},
+	"poly":       {{"poly(2)", "8.25"}},
+	"hypot":      {{"hypot(3, 4)", "5"}},
+	"fconst":     {{"fconst()", "0.3"}},
+	"f32const":   {{"f32const()", "float32(0.1)"}},
+	"mean":       {{"mean(1, 2)", "1.5"}},
+	"lt":         {{"lt(-1, 0)", "true"}, {"lt(0, 0)", "false"}},
+	"ltu":        {{"ltu(1<<63, 1)", "false"}, {"ltu(1, 1<<63)", "true"}},
+	"ge8":        {{"ge8(-128, 127)", "false"}, {"ge8(5, 5)", "true"}},
+	"gtu16":      {{"gtu16(0xffff, 1)", "true"}},
+	"le32c":      {{"le32c(7)", "true"}, {"le32c(8)", "false"}},
+	"eqb":        {{"eqb(true, true)", "true"}, {"eqb(true, false)", "false"}},
+	"eqf":        {{"eqf(1.5, 1.5)", "true"}, {"eqf(nan(), nan())", "false"}},
+	"nef":        {{"nef(1.5, 1.5)", "false"}, {"nef(nan(), nan())", "true"}},
+	"ltf32":      {{"ltf32(-1, 0.5)", "true"}, {"ltf32(0.5, -1)", "false"}},
+	"maxu":       {{"maxu(1<<31, 1)", "1 << 31"}, {"maxu(2, 3)", "3"}},
+	"sign":       {{"sign(-5)", "-1"}, {"sign(0)", "0"}, {"sign(9)", "1"}},
+	"feq":        {{"feq(2, 2)", "1"}, {"feq(2, 3)", "0"}, {"feq(nan(), nan())", "0"}},
+	"fne":        {{"fne(2, 2)", "0"}, {"fne(2, 3)", "1"}, {"fne(nan(), 1)", "1"}},
+	"fge32":      {{"fge32(2, 2)", "1"}, {"fge32(1, 2)", "0"}},
+	"gtu8":       {{"gtu8(0x80, 1)", "1"}, {"gtu8(1, 0x80)", "0"}},
+}
+
+// TestToolchain tests the generated assembly of every file of testdata
+// with the Go toolchain. The assembly and prototypes of a file are a
+// package of their own that go tool asm assembles and go vet checks,
+// then its functions are called with toolchainCalls. A hand-written
+// package with a wrong argument size must fail go vet.
+func TestToolchain(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no go command")
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "*.gir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) { testToolchain(t, goTool, file) })
+	}
+	// vet checks the assembly, a wrong argument size must fail
+	t.Run("argsize", func(t *testing.T) {
+		dir := writePackage(t, map[string]string{
+			"go.mod":        "module args\n",
+			"args_amd64.s":  "#include \"textflag.h\"\n\nTEXT ·f(SB),$0-16\n\tMOVL\tx+0(FP), AX\n\tMOVL\tAX, r+8(FP)\n\tRET\n",
+			"args_proto.go": "// +build amd64\n\npackage args\n\nfunc f(x int32) (r int32)\n",
+		})
+		defer os.RemoveAll(dir)
+		out, err := goCmd(goTool, dir, "vet", ".")
+		if err == nil {
+			t.Fatal("expected go vet to fail")
+		}
+		if !strings.Contains(string(out), "wrong argument size 16; expected $...-12") {
+			t.Errorf("expected a wrong argument size, got:\n%s", out)
+		}
+	})
+}
+
+// testToolchain assembles, vets and calls the functions of the file
+func testToolchain(t *testing.T, goTool, file string) {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := compile.Compile(file, bytes.NewReader(src), compile.Options{})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(res.Funcs) == 0 {
+		return
+	}
+	name := strings.TrimSuffix(filepath.Base(file), ".gir")
+	dir := writePackage(t, map[string]string{
+		"go.mod":              "module " + res.Pkg + "\n",
+		name + "_amd64.s":     res.Asm(),
+		name + "_proto.go":    res.Proto(),
+		name + "_gir_test.go": callsFile(res),
+	})
+	defer os.RemoveAll(dir)
+	goroot, err := exec.Command(goTool, "env", "GOROOT").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	include := filepath.Join(strings.TrimSpace(string(goroot)), "pkg", "include")
+	obj := filepath.Join(dir, name+".o")
+	for _, args := range [][]string{
+		{"tool", "asm", "-I", include, "-o", obj, name + "_amd64.s"},
+		{"vet", "."},
+		{"test", "."},
+	} {
+		if out, err := goCmd(goTool, dir, args...); err != nil {
+			t.Fatalf("go %v: %v\n%s\n%s", strings.Join(args, " "), err, out, res.Asm())
+		}
+		os.Remove(obj)
+	}
+}
+
+// writePackage writes the files to a new temporary directory and
+// returns it
+func writePackage(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "gir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for file, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(contents), 0644); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// goCmd runs the go command in dir for amd64
+func goCmd(goTool, dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command(goTool, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=", "GOARCH=amd64")
+	return cmd.CombinedOutput()
+}
+
+// callsFile returns the Go test calling the compiled functions of res
+// with toolchainCalls
+func callsFile(res *compile.Result) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\nimport \"testing\"\n\nfunc TestCalls(t *testing.T) {\n", res.Pkg)
+	for _, fn := range res.Funcs {
+		for _, c := range toolchainCalls[fn.Name] {
+			fmt.Fprintf(&buf, "\tif got := %s; got != %s {\n", c.call, c.want)
+			fmt.Fprintf(&buf, "\t\tt.Errorf(%q, got)\n\t}\n", c.call+" = %v, want "+c.want)
+		}
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// TestGoProto tests the Go prototypes of assembly functions
+func TestGoProto(t *testing.T) {
+	for _, test := range []struct {
+		sig   string
+		proto string
+	}{
+		{"func()", "func f()\n"},
+		{"func(x int64, y int64) int64", "func f(x, y int64) int64\n"},
+		{"func(x int32, n int32) (r int32)", "func f(x, n int32) (r int32)\n"},
+		{"func(a int8, b int64, c int64) (r bool)", "func f(a int8, b, c int64) (r bool)\n"},
+		{"func(int, int) (int, bool)", "func f(int, int) (int, bool)\n"},
+		{"func(p *int, n int) int", "//go:noescape\nfunc f(p *int, n int) int\n"},
+		{"func(n int) *int", "func f(n int) *int\n"},
+	} {
+		tv, err := types.Eval(gotoken.NewFileSet(), nil, gotoken.NoPos, test.sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proto := codegen.GoProto("f", tv.Type.(*types.Signature)); proto != test.proto {
+			t.Errorf("%v: expected %q, got %q", test.sig, test.proto, proto)
+		}
+	}
+}
+
+// TestArith tests that the integer binary operators are lowered to the
+// op of their width and signedness, in the block of the operation
+func TestArith(t *testing.T) {
+	checkAsm(t, "arith.gir", map[string][]string{
+		"sub":    {`^SUBQ\t$r, $r$`},
+		"add8":   {`^LEAL\t\($r\)\($r\*1\), $r$`},
+		"mul32":  {`^IMULL\t$r, $r$`},
+		"quo":    {`^CMPQ\t$r, \$-1$`, `^CQO$`, `^IDIVQ\t$r$`},
+		"rem":    {`^CQO$`, `^IDIVQ\t$r$`},
+		"quo16":  {`^CMPW\t$r, \$-1$`, `^CWD$`, `^IDIVW\t$r$`},
+		"quou":   {`^XORQ\tDX, DX$`, `^DIVL\t$r$`},
+		"remu8":  {`^XORQ\tDX, DX$`, `^DIVW\t$r$`},
+		"and8":   {`^ANDL\t$r, $r$`},
+		"or16":   {`^ORL\t$r, $r$`},
+		"xor":    {`^XORQ\t$r, $r$`},
+		"andNot": {`^NOTL\t$r$`, `^ANDL\t$r, $r$`},
+	})
+	src := `package arith
+
+func quoIf(x int64, y int64) (r int64) {
+	c = y == 0
+	if c goto b2 else b3
+b2:
+	r = 0
+	return
+b3:
+	r = x / y
+	return
+}
+`
+	res, err := compile.Compile("arith.gir", strings.NewReader(src), compile.Options{Gir: true, Pass: "early phielim"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gir := res.Funcs[0].Gir
+	entry := gir[:strings.Index(gir, "\nb")]
+	if !strings.Contains(gir, "Div64") || strings.Contains(entry, "Div64") {
+		t.Errorf("expected Div64 after the entry block:\n%v", gir)
+	}
+}
+
+// TestRotate tests that rotates type check as shifts of their value
+// and must be assigned
+func TestRotate(t *testing.T) {
+	rotates := 0
+	inspectFile(t, "shift.gir", func(n ast.Node, fn *types.Func, info *types.Info) {
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			return
+		}
+		if call, _, ok := gimporter.IsRotate(expr); ok {
+			rotates++
+			if x, rot := info.TypeOf(call.Args[0]), info.TypeOf(call); rot != x {
+				t.Errorf("%v: expected rotate of type %v, got %v", fn.Name(), x, rot)
+			}
+		}
+	})
+	if rotates != 4 {
+		t.Errorf("expected 4 rotates, got %v", rotates)
+	}
+	checkAsm(t, "shift.gir", map[string][]string{
+		"rotl16c": {`ROLW\t\$4, $r`},
+		"rotr8c":  {`ROLB\t\$5, $r`},
+	})
+	src := `package rot
+
+func f(x uint32) (r uint32) {
+	r = rotl(x, 1) + 1
+	return
+}
+
+func g(x float64) (r float64) {
+	r = rotr(x, 1)
+	return
+}
+
+func h(x int64, k int64) (r int64) {
+	r = x << k
+	return
+}
+`
+	res, _ := compile.Compile("rot.gir", strings.NewReader(src), compile.Options{})
+	expected := []string{
+		"rot.gir:3:1: f: rotl(x, 1) must be assigned to a variable",
+		"rot.gir:9:11: g: invalid operation",
+		"rot.gir:14:11: h: shift count k must be unsigned",
+	}
+	if len(res.Diagnostics) != len(expected) {
+		t.Fatalf("expected %v diagnostics, got %v", len(expected), res.Diagnostics)
+	}
+	for i, e := range expected {
+		if got := res.Diagnostics[i].Error(); !strings.HasPrefix(got, e) {
+			t.Errorf("expected %v, got %v", e, got)
+		}
+	}
+}
+
+// TestConversion tests that conversions type check to their numeric
+// type and infer the types of locals
+func TestConversion(t *testing.T) {
+	conversions := 0
+	inspectFile(t, "conv.gir", func(n ast.Node, fn *types.Func, info *types.Info) {
+		if ident, ok := n.(*ast.Ident); ok && ident.Name == "w" {
+			if obj := info.Defs[ident]; obj != nil && obj.Type().String() != "int64" {
+				t.Errorf("%v: expected w of type int64, got %v", fn.Name(), obj.Type())
+			}
+		}
+		expr, ok := n.(ast.Expr)
+		if !ok {
+			return
+		}
+		if call, ok := gimporter.IsConversion(expr); ok {
+			conversions++
+			if got, want := info.TypeOf(call).String(), call.Fun.(*ast.Ident).Name; got != want {
+				t.Errorf("%v: expected conversion to %v, got %v", fn.Name(), want, got)
+			}
+		}
+	})
+	if conversions != 16 {
+		t.Errorf("expected 16 conversions, got %v", conversions)
+	}
+	checkAsm(t, "conv.gir", map[string][]string{
+		"i2f":      {`CVTSL2SD\t$r, $x`},
+		"f32tof64": {`CVTSS2SD\t$x, $x`},
+		"f64tof32": {`CVTSD2SS\t$x, $x`},
+	})
+	src := `package conv
+
+func f(x int64) (r int32) {
+	r = int32(x, x)
+	return
+}
+
+func g(x int64) (r string) {
+	r = string(x)
+	return
+}
+`
+	res, _ := compile.Compile("conv.gir", strings.NewReader(src), compile.Options{})
+	expected := []string{
+		"conv.gir:3:1: f: int32(x, x) needs one value to convert",
+		"conv.gir:8:1: g: undefined: string",
+	}
+	if len(res.Diagnostics) != len(expected) {
+		t.Fatalf("expected %v diagnostics, got %v", len(expected), res.Diagnostics)
+	}
+	for i, e := range expected {
+		if got := res.Diagnostics[i].Error(); got != e {
+			t.Errorf("expected %v, got %v", e, got)
+		}
+	}
+}
+
+// TestFloat tests that float literals parse and type check to the float
+// type of their use and that sqrt takes a float64
+func TestFloat(t *testing.T) {
+	var conf config.Config
+	for _, test := range []struct {
+		lit  string
+		want value.Value
+	}{
+		{"1.5", value.Float(1.5)},
+		{"1e3", value.Float(1000)},
+		{"2.", value.Float(2)},
+		{"25e-2", value.Float(0.25)},
+		{"0x1e", value.Int(30)},
+		{"42", value.Int(42)},
+	} {
+		v, err := value.Parse(&conf, test.lit)
+		if err != nil {
+			t.Errorf("%v: %v", test.lit, err)
+		} else if v != test.want {
+			t.Errorf("%v: expected %#v, got %#v", test.lit, test.want, v)
+		}
+	}
+	if got := value.Float(2).ProgString(); got != "2.0" {
+		t.Errorf("expected 2.0, got %v", got)
+	}
+	lits := 0
+	inspectFile(t, "float.gir", func(n ast.Node, fn *types.Func, info *types.Info) {
+		if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == gotoken.FLOAT {
+			lits++
+			want := fn.Type().(*types.Signature).Results().At(0).Type()
+			if got := info.TypeOf(lit); got != want {
+				t.Errorf("%v: expected %v of type %v, got %v", fn.Name(), lit.Value, want, got)
+			}
+		}
+	})
+	if lits != 7 {
+		t.Errorf("expected 7 float literals, got %v", lits)
+	}
+	checkAsm(t, "float.gir", map[string][]string{
+		"fadd":   {`ADDSD\t$x, $x`},
+		"fsub32": {`SUBSS\t$x, $x`},
+		"hypot":  {`SQRTSD\t$x, $x`},
+	})
+	src := `package float
+
+func f(x float32) (r float64) {
+	r = sqrt(x)
+	return
+}
+
+func g(x float64) (r float64) {
+	r = sqrt(x, x)
+	return
+}
+`
+	res, _ := compile.Compile("float.gir", strings.NewReader(src), compile.Options{})
+	expected := []string{
+		"float.gir:4:11: f: cannot use x",
+		"float.gir:8:1: g: sqrt(x, x) needs one value",
+	}
+	if len(res.Diagnostics) != len(expected) {
+		t.Fatalf("expected %v diagnostics, got %v", len(expected), res.Diagnostics)
+	}
+	for i, e := range expected {
+		if got := res.Diagnostics[i].Error(); !strings.HasPrefix(got, e) {
+			t.Errorf("expected %v, got %v", e, got)
+		}
+	}
+}
+
+// TestCompare tests that comparisons type check to bool, as values and
+// as the conditions of ifs
+func TestCompare(t *testing.T) {
+	for _, fnDecl := range parseFile(t, "cmp.gir").Decls {
+		if fnDecl.Name == "maxu" {
+			if tree := parse.Tree(fnDecl.Body.List[0]); tree != "if (<var x> < <var y>) goto b2 else b3" {
+				t.Errorf("unexpected if %v", tree)
+			}
+		}
+	}
+	comparisons := 0
+	inspectFile(t, "cmp.gir", func(n ast.Node, fn *types.Func, info *types.Info) {
+		if b, ok := n.(*ast.BinaryExpr); ok && b.Op.Precedence() == gotoken.EQL.Precedence() {
+			comparisons++
+			if typ := types.Default(info.TypeOf(b)).String(); typ != "bool" {
+				t.Errorf("%v: expected bool comparison, got %v", fn.Name(), typ)
+			}
+		}
+	})
+	if comparisons != 16 {
+		t.Errorf("expected 16 comparisons, got %v", comparisons)
+	}
+}
+
+// TestFusedBranches tests that the comparison of an if is fused into its
+// conditional jump without a SETcc
+func TestFusedBranches(t *testing.T) {
+	expected := map[string][]string{
+		"maxu":  {`CMPL\t`, `\tJ(CS|CC|HI|LS)\t`},
+		"sign":  {`\tJ(LT|GE)\t`, `\tJ(EQ|NE)\t`},
+		"feq":   {`UCOMISD\t`, `\tJNE\t`, `\tJP(S|C)\t`},
+		"fne":   {`UCOMISD\t`, `\tJNE\t`, `\tJP(S|C)\t`},
+		"fge32": {`UCOMISS\t`, `\tJ(CC|CS)\t`},
+		"gtu8":  {`CMPB\t`, `\tJ(HI|LS)\t`},
+	}
+	for _, fn := range checkAsm(t, "cmp.gir", expected) {
+		if _, ok := expected[fn.Name]; ok && strings.Contains(fn.Asm, "\tSET") {
+			t.Errorf("%v: comparison not fused into the branch:\n%v", fn.Name, fn.Asm)
+		}
+	}
+}
+
+// TestConst tests that constant expressions are type checked and folded
+func TestConst(t *testing.T) {
+	for _, test := range []struct {
+		file  string
+		value string
+		typ   string
+	}{
+		{"test3.gir", "36", "int"},
+		{"const.gir", "7", "int64"},
+	} {
+		fileDecl := parseFile(t, test.file)
+		decl, err := gimporter.FuncDecl(&fileDecl.Decls[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, info, err := gimporter.Check(fileDecl.PkgName, decl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assign, ok := decl.Body.List[0].(*ast.AssignStmt)
+		if !ok {
+			t.Fatalf("%v: expected assignment, got %T", test.file, decl.Body.List[0])
+		}
+		tv := info.Types[assign.Rhs[0]]
+		if tv.Value == nil || tv.Value.String() != test.value || tv.Type.String() != test.typ {
+			t.Errorf("%v: expected %v %v, got %v %v", test.file, test.typ, test.value, tv.Type, tv.Value)
+		}
+	}
+}
+
+// TestPhi tests explicit phis
+func TestPhi(t *testing.T) {
+	fileDecl := parseFile(t, "phi.gir")
+	fnDecl := fileDecl.Decls[0]
+	labeled, ok := fnDecl.Body.List[3].(*gst.LabeledStmt)
+	if !ok {
+		t.Fatalf("expected labeled statement, got %T", fnDecl.Body.List[3])
+	}
+	if tree := parse.Tree(labeled.Stmt); tree != "(<var i1> = (phi <<var i0>; <var i2>>))" {
+		t.Errorf("unexpected phi %v", tree)
+	}
+	decl, err := gimporter.FuncDecl(&fnDecl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, info, err := gimporter.Check(fileDecl.PkgName, decl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range decl.Body.List {
+		if labeled, ok := stmt.(*ast.LabeledStmt); ok {
+			stmt = labeled.Stmt
+		}
+		assign, ok := stmt.(*ast.AssignStmt)
+		if !ok {
+			continue
+		}
+		call, ok := gimporter.IsPhi(assign.Rhs[0])
+		if !ok {
+			continue
+		}
+		if typ := info.TypeOf(call).String(); typ != "int" {
+			t.Errorf("expected int phi, got %v", typ)
+		}
+		for _, arg := range call.Args {
+			if info.Uses[arg.(*ast.Ident)] == nil {
+				t.Errorf("phi argument %v not resolved", arg)
+			}
+		}
+	}
+
+	// the arguments must have the type of the phi
+	labeled.Stmt.(*gst.AssignStmt).Rhs.(*gst.CallExpr).Args[1] = &gst.Ident{Name: "c"}
+	decl, err = gimporter.FuncDecl(&fnDecl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := gimporter.Check(fileDecl.PkgName, decl); err == nil {
+		t.Error("expected phi type mismatch error")
+	}
+}
+
+// TestOp tests SSA ops written as in SSA dumps
+func TestOp(t *testing.T) {
+	fileDecl := parseFile(t, "op.gir")
+	list := fileDecl.Decls[0].Body.List
+	for i, expected := range []string{
+		"(<var a> = (op Arg <int64> {x}))",
+		"(<var c> = (op Const64 <int64> [-8]))",
+		"(<var s> = (op Add64 <int64> a y))",
+	} {
+		if tree := parse.Tree(list[i]); tree != expected {
+			t.Errorf("expected %v, got %v", expected, tree)
+		}
+	}
+	for _, fnDecl := range fileDecl.Decls {
+		decl, err := gimporter.FuncDecl(&fnDecl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, info, err := gimporter.Check(fileDecl.PkgName, decl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, stmt := range decl.Body.List {
+			assign, ok := stmt.(*ast.AssignStmt)
+			if !ok {
+				continue
+			}
+			op, ok := gimporter.IsOp(assign.Rhs[0])
+			if !ok {
+				t.Fatalf("expected op, got %T", assign.Rhs[0])
+			}
+			if typ := info.TypeOf(op.Call).String(); typ != op.Type {
+				t.Errorf("%v: expected type %v, got %v", op.Name, op.Type, typ)
+			}
+			if op.Name == "Const64" && op.AuxInt != -8 {
+				t.Errorf("expected auxint -8, got %v", op.AuxInt)
+			}
+			if op.Name == "Arg" && (op.Aux == nil || info.Uses[op.Aux] == nil) {
+				t.Errorf("Arg aux x not resolved")
+			}
+		}
+	}
+	src := `package ops
+
+func f(x int64) (r int64) {
+	r = Bogus64 <int64> x
+	return
+}
+
+func g(x int64) (r int64) {
+b1:
+	v = Arg <flags> {x}
+	if BOGUS v goto b2 else b2
+b2:
+	return
+}
+`
+	res, _ := compile.Compile("ops.gir", strings.NewReader(src), compile.Options{})
+	expected := []string{
+		"ops.gir:4:6: f: unknown op Bogus64",
+		"ops.gir:11:2: g: unknown block kind BOGUS",
+	}
+	if len(res.Diagnostics) != len(expected) {
+		t.Fatalf("expected %v diagnostics, got %v", len(expected), res.Diagnostics)
+	}
+	for i, e := range expected {
+		if got := res.Diagnostics[i].Error(); got != e {
+			t.Errorf("expected %v, got %v", e, got)
+		}
+	}
+}
+
+// TestOpLists tests that the ops known by name are the amd64 ops
+// genValue assembles, and that the generic ops codegen builds are known
+func TestOpLists(t *testing.T) {
+	listed := opConsts(t, filepath.Join("gimporter", "op.go"), "amd64Ops")
+	assembled := map[string]bool{}
+	for op := range opConsts(t, filepath.Join("codegen", "assembler.go"), "genValue") {
+		if strings.HasPrefix(op, "AMD64") {
+			assembled[op] = true
+		}
+	}
+	for op := range assembled {
+		if !listed[op] {
+			t.Errorf("%v is assembled but not listed", op)
+		}
+	}
+	for op := range listed {
+		if !assembled[op] {
+			t.Errorf("%v is listed but not assembled", op)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join("codegen", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		for op := range opConsts(t, file, "") {
+			if _, ok := gimporter.LookupOp(op); !ok && !strings.HasPrefix(op, "AMD64") && op != "Invalid" {
+				t.Errorf("%v: %v is built but not known", file, op)
+			}
+		}
+	}
+}
+
+// opConsts returns the names of the ssa.Op constants, without the "Op",
+// used by the declaration, decl, of the file, or by the file if decl
+// is empty
+func opConsts(t *testing.T, file, decl string) map[string]bool {
+	f, err := goparser.ParseFile(gotoken.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			return decl == "" || n.Name.Name == decl
+		case *ast.ValueSpec:
+			return decl == "" || n.Names[0].Name == decl
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok && x.Name == "ssa" && strings.HasPrefix(n.Sel.Name, "Op") && n.Sel.Name != "Op" {
+				ops[strings.TrimPrefix(n.Sel.Name, "Op")] = true
+			}
+		}
+		return true
+	})
+	if decl != "" && len(ops) == 0 {
+		t.Fatalf("%v: no ops in %v", file, decl)
+	}
+	return ops
+}
+
+// TestDump tests parsing GOSSAFUNC dumps
+func TestDump(t *testing.T) {
+	fd, err := os.Open(filepath.Join("testdata", "max.ssa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	fns, err := dump.Parse("max.ssa", fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fns) != 1 || fns[0].Name != "max" || fns[0].Type != "func(int, int) int" {
+		t.Fatalf("expected max func(int, int) int, got %v", fns)
+	}
+	blocks := fns[0].Blocks
+	if len(blocks) != 4 {
+		t.Fatalf("expected 4 blocks, got %v", len(blocks))
+	}
+	if b := blocks[0]; b.Kind != "If" || b.Control != 9 || !reflect.DeepEqual(b.Succs, []int{3, 2}) {
+		t.Errorf("expected If v9 -> b3 b2, got %v v%v -> %v", b.Kind, b.Control, b.Succs)
+	}
+	if preds := blocks[3].Preds; !reflect.DeepEqual(preds, []int{2, 3}) {
+		t.Errorf("expected b4 <- b2 b3, got %v", preds)
+	}
+	v := blocks[0].Values[3]
+	if v.ID != 4 || v.Op != "Addr" || v.Type != "*int" || v.Aux != "a" || !reflect.DeepEqual(v.Args, []int{2}) {
+		t.Errorf("expected v4 = Addr <*int> {a} v2, got %+v", v)
+	}
+	v = blocks[3].Values[2]
+	if v.Op != "Store" || v.AuxInt != "8" || !reflect.DeepEqual(v.Args, []int{6, 10, 11}) {
+		t.Errorf("expected v12 = Store <mem> [8] v6 v10 v11, got %+v", v)
+	}
+	if _, err := dump.Parse("bad.ssa", strings.NewReader("f func()\n  b1:\n    v1 = InitMem\n")); err == nil {
+		t.Error("expected missing type error")
+	}
+}
+
+// TestGenGir tests printing SSA functions as GIR and parsing them again
+func TestGenGir(t *testing.T) {
+	fd, err := os.Open(filepath.Join("testdata", "max.ssa"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	fns, err := dump.Parse("max.ssa", fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gir, err := dump.Gir(fns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `func max(a int, b int) (r2 int) {
+	v1 = InitMem <mem>
+	v2 = SP <uintptr>
+	v3 = SB <uintptr>
+	v4 = Addr <*int> {a} v2
+	v5 = Addr <*int> {b} v2
+	v6 = Addr <*int> {r2} v2
+	v7 = Arg <int> {a}
+	v8 = Arg <int> {b}
+	v9 = Greater64 <bool> v7 v8
+	if v9 goto b3 else b2
+b2:
+	goto b4
+b3:
+	goto b4
+b4:
+	v10 = phi(v8, v7)
+	v11 = VarDef <mem> {r2} v1
+	v12 = Store <mem> [8] v6 v10 v11
+	return
+}
+`
+	if gir != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, gir)
+	}
+	// the params are named by name, not by the order of their values
+	fns[0].Type = "func(b, a int) int"
+	gir, err = dump.Gir(fns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(gir, "func max(b int, a int) (r2 int) {\n") {
+		t.Errorf("expected func max(b int, a int) (r2 int), got\n%v", gir)
+	}
+	if vars := fns[0].Vars([]string{"", "", ""}, 2); !reflect.DeepEqual(vars, []string{"a", "b", "~r2"}) {
+		t.Errorf("expected the vars a, b and ~r2, got %v", vars)
+	}
+	if vars := fns[0].Vars([]string{"b", "a", "r"}, 2); !reflect.DeepEqual(vars, []string{"b", "a", ""}) {
+		t.Errorf("expected the vars b, a and none, got %v", vars)
+	}
+	fns[0].Type = "func(int, int) int"
+	// a machine block
+	fns[0].Blocks[0].Kind = "GT"
+	gir, err = dump.Gir(fns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(gir, "\tif GT v9 goto b3 else b2\n") {
+		t.Errorf("expected if GT v9 goto b3 else b2, got\n%v", gir)
+	}
+	context := ctx.NewContext(&conf)
+	scanner := scan.New(context, "max.gir", bufio.NewReader(strings.NewReader("package testdata\n\n"+gir)))
+	fileDecl, err := parse.NewParser("max.gir", scanner, context).ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decl, err := gimporter.FuncDecl(&fileDecl.Decls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, info, err := gimporter.Check(fileDecl.PkgName, decl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{}
+	for ident, obj := range info.Defs {
+		if obj != nil {
+			types[ident.Name] = obj.Type().String()
+		}
+	}
+	for name, typ := range map[string]string{"v1": "ssa.mem", "v4": "*int", "v10": "int", "r2": "int"} {
+		if types[name] != typ {
+			t.Errorf("expected %v of type %v, got %v", name, typ, types[name])
+		}
+	}
+	for _, stmt := range decl.Body.List {
+		if stmt, ok := stmt.(*ast.IfStmt); ok {
+			if kind, control, _ := gimporter.IsBlockKind(stmt.Cond); kind != "GT" || info.Uses[control] == nil {
+				t.Errorf("expected block of kind GT with control v9")
+			}
+		}
+	}
+}
+
+// TestGenGirAux tests printing the autos, spill slots and strings named
+// by aux as GIR and type checking them
+func TestGenGirAux(t *testing.T) {
+	src := `f func(int) int
+  b1:
+    v1 = InitMem <mem>
+    v2 = SP <uintptr>
+    v3 = Arg <int> {x}
+    v4 = Addr <*int> {.autotmp_1} v2
+    v5 = Store <mem> [8] v4 v3 v1
+    v6 = StoreReg <int> v3 : autotmp_0
+    v7 = ConstString <string> {"hi"}
+  Ret v5
+`
+	fns, err := dump.Parse("aux.ssa", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fns[0].Blocks[0].Values[5].Aux = "autotmp_0" // as codegen.Dump names a spill slot
+	gir, err := dump.Gir(fns[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `func f(x int) (r1 int) {
+	v1 = InitMem <mem>
+	v2 = SP <uintptr>
+	v3 = Arg <int> {x}
+	v4 = Addr <*int> {autotmp_1} v2
+	v5 = Store <mem> [8] v4 v3 v1
+	v6 = StoreReg <int> {autotmp_0} v3
+	v7 = ConstString <string> {"hi"}
+	return
+}
+`
+	if gir != expected {
+		t.Errorf("expected\n%v\ngot\n%v", expected, gir)
+	}
+	context := ctx.NewContext(&conf)
+	scanner := scan.New(context, "aux.gir", bufio.NewReader(strings.NewReader("package testdata\n\n"+gir)))
+	fileDecl, err := parse.NewParser("aux.gir", scanner, context).ParseFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decl, err := gimporter.FuncDecl(&fileDecl.Decls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, info, err := gimporter.Check(fileDecl.PkgName, decl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]string{}
+	for ident, obj := range info.Defs {
+		if obj != nil {
+			types[ident.Name] = obj.Type().String()
+		}
+	}
+	for name, typ := range map[string]string{"autotmp_1": "int", "autotmp_0": "int", "v7": "string"} {
+		if types[name] != typ {
+			t.Errorf("expected %v of type %v, got %v", name, typ, types[name])
+		}
+	}
+}
+
+// TestGirRoundTrip compiles every file of testdata, prints its functions
+// as GIR after the lower pass and compiles the GIR again to the same
+// assembly. The functions print as GIR after the last pass too.
+func TestGirRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.gir"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := compile.Compile(file, bytes.NewReader(src), compile.Options{Gir: true, Pass: "lower"})
+		if err != nil {
+			t.Errorf("%v: %v", file, err)
+			continue
+		}
+		again, err := compile.Compile(file, strings.NewReader(res.Gir()), compile.Options{})
+		if err != nil {
+			t.Errorf("%v: %v, GIR after lower:\n%v", file, err, res.Gir())
+			continue
+		}
+		if again.Asm() != res.Asm() {
+			t.Errorf("%v: expected assembly:\n%v\ngot:\n%v", file, res.Asm(), again.Asm())
+		}
+		if _, err := compile.Compile(file, bytes.NewReader(src), compile.Options{Gir: true}); err != nil {
+			t.Errorf("%v: %v", file, err)
+		}
+	}
+}
+
 func TestGir(t *testing.T) {
 	var (
 		conf    config.Config
@@ -61,7 +1545,7 @@ func TestGir(t *testing.T) {
 		err     error
 	)
 	context = ctx.NewContext(&conf)
-	for _, file := range []string{filepath.Join("testdata", "test.gir"), filepath.Join("testdata", "test1.gir"), filepath.Join("testdata", "test2.gir"), filepath.Join("testdata", "test3.gir"), filepath.Join("testdata", "test4.gir")} {
+	for _, file := range []string{filepath.Join("testdata", "test.gir"), filepath.Join("testdata", "test1.gir"), filepath.Join("testdata", "test2.gir"), filepath.Join("testdata", "test3.gir"), filepath.Join("testdata", "test4.gir"), filepath.Join("testdata", "params.gir"), filepath.Join("testdata", "block.gir"), filepath.Join("testdata", "assign.gir"), filepath.Join("testdata", "const.gir"), filepath.Join("testdata", "goto.gir"), filepath.Join("testdata", "if.gir"), filepath.Join("testdata", "phi.gir"), filepath.Join("testdata", "op.gir"), filepath.Join("testdata", "max.gir"), filepath.Join("testdata", "arith.gir"), filepath.Join("testdata", "shift.gir"), filepath.Join("testdata", "conv.gir"), filepath.Join("testdata", "float.gir"), filepath.Join("testdata", "cmp.gir")} {
 		fd, err = os.Open(file)
 		defer fd.Close()
 		if err != nil {
@@ -69,13 +1553,16 @@ func TestGir(t *testing.T) {
 		}
 		scanner := scan.New(context, file, bufio.NewReader(fd))
 		parser := parse.NewParser(file, scanner, context)
-		fileDecl := parser.ParseFile()
+		fileDecl, err := parser.ParseFile()
+		if err != nil {
+			t.Fatal(err)
+		}
 		t.Log("tree(exprs): ", parse.Tree(fileDecl))
 
 		for _, fnDecl := range fileDecl.Decls {
-			ssafn, ok := codegen.BuildSSA(&fnDecl, fileDecl.PkgName, false)
-			if ssafn == nil || !ok {
-				t.Fatalf("gir: Error building SSA form")
+			ssafn, err := codegen.Build(&fnDecl, fileDecl.PkgName, false)
+			if err != nil {
+				t.Fatalf("gir: %v", err)
 				return
 			} else {
 				t.Log("ssa:\n", ssafn)
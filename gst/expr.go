@@ -0,0 +1,127 @@
+package gst
+
+import (
+	"fmt"
+
+	"github.com/bjwbell/gir/token"
+	"github.com/bjwbell/gir/value"
+)
+
+// Ident is a variable name.
+type Ident struct {
+	NamePos token.Pos
+	Name    string
+}
+
+func (id *Ident) Pos() token.Pos {
+	return id.NamePos
+}
+
+func (id *Ident) ProgString() string {
+	return id.Name
+}
+
+// UnaryExpr is a unary expression, Op X.
+type UnaryExpr struct {
+	OpPos token.Pos
+	Op    string
+	X     value.Expr
+}
+
+func (u *UnaryExpr) Pos() token.Pos {
+	return u.OpPos
+}
+
+func (u *UnaryExpr) ProgString() string {
+	return fmt.Sprintf("%s%s", u.Op, u.X.ProgString())
+}
+
+// BinaryExpr is a binary expression, X Op Y.
+type BinaryExpr struct {
+	OpPos token.Pos
+	Op    string
+	X     value.Expr
+	Y     value.Expr
+}
+
+// Pos returns the position of X or, for a constant X, of Op.
+func (b *BinaryExpr) Pos() token.Pos {
+	if pos := ExprPos(b.X); pos.IsValid() {
+		return pos
+	}
+	return b.OpPos
+}
+
+func (b *BinaryExpr) ProgString() string {
+	if b.Op == "[]" {
+		return fmt.Sprintf("%s[%s]", b.X.ProgString(), b.Y.ProgString())
+	}
+	return fmt.Sprintf("(%s %s %s)", b.X.ProgString(), b.Op, b.Y.ProgString())
+}
+
+// CallExpr is a call of a builtin, Fun(Args...), like phi(x1, x2).
+type CallExpr struct {
+	Fun  *Ident
+	Args []value.Expr
+}
+
+func (c *CallExpr) Pos() token.Pos {
+	return c.Fun.Pos()
+}
+
+func (c *CallExpr) ProgString() string {
+	s := c.Fun.Name + "("
+	for i, arg := range c.Args {
+		if i > 0 {
+			s += ", "
+		}
+		s += arg.ProgString()
+	}
+	return s + ")"
+}
+
+// OpExpr is an SSA op written as in the Go compiler's SSA dumps,
+// "Op <Type> [AuxInt] {Aux} Args...", like "Add64 <int64> v2 v3".
+// Aux is nil if the op has none or its aux is a string, AuxString is
+// then the quoted string, "\"str\"".
+type OpExpr struct {
+	OpPos     token.Pos
+	Op        string
+	Type      string
+	AuxInt    int64
+	Aux       *Ident
+	AuxString string
+	Args      []*Ident
+}
+
+func (o *OpExpr) Pos() token.Pos {
+	return o.OpPos
+}
+
+func (o *OpExpr) ProgString() string {
+	s := fmt.Sprintf("%s <%s>", o.Op, o.Type)
+	if o.AuxInt != 0 {
+		s += fmt.Sprintf(" [%d]", o.AuxInt)
+	}
+	if o.Aux != nil {
+		s += fmt.Sprintf(" {%s}", o.Aux.Name)
+	}
+	if o.AuxString != "" {
+		s += fmt.Sprintf(" {%s}", o.AuxString)
+	}
+	for _, arg := range o.Args {
+		s += " " + arg.Name
+	}
+	return s
+}
+
+// ExprPos returns the position of the expression, expr, constants
+// have none.
+func ExprPos(expr value.Expr) token.Pos {
+	if n, ok := expr.(interface {
+		Pos() token.Pos
+	}); ok {
+		return n.Pos()
+	}
+	return token.Pos{}
+}
@@ -1,6 +1,11 @@
 package gst
 
+import (
+	"github.com/bjwbell/gir/token"
+)
+
 type File struct {
+	Package token.Pos // position of "package"
 	PkgName string
 	Decls   []FuncDecl
 }
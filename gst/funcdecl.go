@@ -1,6 +1,22 @@
 package gst
 
-type FuncDecl struct {
+import (
+	"github.com/bjwbell/gir/token"
+)
+
+// Field is a function parameter or result, Name is empty if unnamed.
+// Pos is the position of its name, or of its type if it's unnamed.
+type Field struct {
+	Pos  token.Pos
 	Name string
-	Body Stmt
+	Type string
+}
+
+type FuncDecl struct {
+	Func    token.Pos // position of "func"
+	NamePos token.Pos
+	Name    string
+	Params  []Field
+	Results []Field
+	Body    *BlockStmt
 }
@@ -1,22 +1,107 @@
 package gst
 
 import (
+	"github.com/bjwbell/gir/token"
 	"github.com/bjwbell/gir/value"
 )
 
+// Stmt is a statement, Pos is the position of its first token.
 type Stmt interface {
+	Pos() token.Pos
 	stmt()
 }
 
+type BlockStmt struct {
+	Lbrace token.Pos // position of "{"
+	List   []Stmt
+}
+
+func (b *BlockStmt) Pos() token.Pos {
+	return b.Lbrace
+}
+
+func (b *BlockStmt) stmt() {
+}
+
 type ExprStmt struct {
-	Exprs []value.Expr 
+	Start token.Pos // position of the first expression
+	Exprs []value.Expr
+}
+
+func (s *ExprStmt) Pos() token.Pos {
+	return s.Start
 }
 
 func (s *ExprStmt) stmt() {
 }
 
+// AssignStmt is an assignment, Lhs = Rhs.
+type AssignStmt struct {
+	Lhs    *Ident
+	Assign token.Pos // position of "="
+	Rhs    value.Expr
+}
+
+func (s *AssignStmt) Pos() token.Pos {
+	return s.Lhs.Pos()
+}
+
+func (s *AssignStmt) stmt() {
+}
+
+// LabeledStmt is a statement, Stmt, labeled with Label, the label starts
+// a basic block.
+type LabeledStmt struct {
+	Label *Ident
+	Stmt  Stmt
+}
+
+func (s *LabeledStmt) Pos() token.Pos {
+	return s.Label.Pos()
+}
+
+func (s *LabeledStmt) stmt() {
+}
+
+// GotoStmt is an unconditional branch to the block labeled Label.
+type GotoStmt struct {
+	Goto  token.Pos // position of "goto"
+	Label *Ident
+}
+
+func (s *GotoStmt) Pos() token.Pos {
+	return s.Goto
+}
+
+func (s *GotoStmt) stmt() {
+}
+
+// IfStmt is a conditional branch, to the block labeled Yes if Cond
+// is true and otherwise to the block labeled No. Kind is the kind of a
+// machine block, "NE" in "if NE v5 goto b2 else b3", its Cond is the
+// control of the block. Kind is empty for an If block.
+type IfStmt struct {
+	If   token.Pos // position of "if"
+	Kind string
+	Cond value.Expr
+	Yes  *Ident
+	No   *Ident
+}
+
+func (s *IfStmt) Pos() token.Pos {
+	return s.If
+}
+
+func (s *IfStmt) stmt() {
+}
+
 type RetStmt struct {
+	Return token.Pos // position of "return"
+}
+
+func (ret *RetStmt) Pos() token.Pos {
+	return ret.Return
 }
 
-func (ret *RetStmt) stmt() {	
+func (ret *RetStmt) stmt() {
 }
@@ -4,6 +4,7 @@ package parse
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/bjwbell/gir/gst"
 	"github.com/bjwbell/gir/scan"
@@ -19,25 +20,67 @@ func Tree(e interface{}) string {
 	case []gst.FuncDecl:
 		s := ""
 		for _, fn := range e {
-			s += fmt.Sprintf("func %s() {\n%s\n}", fn.Name, Tree(fn.Body))
+			s += fmt.Sprintf("func %s(%s)", fn.Name, Tree(fn.Params))
+			if len(fn.Results) > 0 {
+				s += fmt.Sprintf(" (%s)", Tree(fn.Results))
+			}
+			s += fmt.Sprintf(" {\n%s\n}", Tree(fn.Body))
+		}
+		return s
+	case []gst.Field:
+		s := ""
+		for i, field := range e {
+			if i > 0 {
+				s += ", "
+			}
+			if field.Name != "" {
+				s += field.Name + " "
+			}
+			s += field.Type
+		}
+		return s
+	case *gst.BlockStmt:
+		s := ""
+		for i, stmt := range e.List {
+			if i > 0 {
+				s += "\n"
+			}
+			s += Tree(stmt)
 		}
 		return s
 	case *gst.RetStmt:
 		return fmt.Sprintf("ret")
 	case *gst.ExprStmt:
 		return fmt.Sprintf("%s", Tree(e.Exprs))
+	case *gst.AssignStmt:
+		return fmt.Sprintf("(%s = %s)", Tree(e.Lhs), Tree(e.Rhs))
+	case *gst.LabeledStmt:
+		return fmt.Sprintf("%s:\n%s", e.Label.Name, Tree(e.Stmt))
+	case *gst.GotoStmt:
+		return fmt.Sprintf("goto %s", e.Label.Name)
+	case *gst.IfStmt:
+		if e.Kind != "" {
+			return fmt.Sprintf("if %s %s goto %s else %s", e.Kind, Tree(e.Cond), e.Yes.Name, e.No.Name)
+		}
+		return fmt.Sprintf("if %s goto %s else %s", Tree(e.Cond), e.Yes.Name, e.No.Name)
 	case value.Int:
 		return fmt.Sprintf("<int %s>", e)
-	case variableExpr:
-		return fmt.Sprintf("<var %s>", e.name)
-	case *unary:
-		return fmt.Sprintf("(%s %s)", e.op, Tree(e.right))
-	case *binary:
+	case value.Float:
+		return fmt.Sprintf("<float %s>", e)
+	case *gst.Ident:
+		return fmt.Sprintf("<var %s>", e.Name)
+	case *gst.UnaryExpr:
+		return fmt.Sprintf("(%s %s)", e.Op, Tree(e.X))
+	case *gst.CallExpr:
+		return fmt.Sprintf("(%s %s)", e.Fun.Name, Tree(e.Args))
+	case *gst.OpExpr:
+		return fmt.Sprintf("(op %s)", e.ProgString())
+	case *gst.BinaryExpr:
 		// Special case for [].
-		if e.op == "[]" {
-			return fmt.Sprintf("(%s[%s])", Tree(e.left), Tree(e.right))
+		if e.Op == "[]" {
+			return fmt.Sprintf("(%s[%s])", Tree(e.X), Tree(e.Y))
 		}
-		return fmt.Sprintf("(%s %s %s)", Tree(e.left), e.op, Tree(e.right))
+		return fmt.Sprintf("(%s %s %s)", Tree(e.X), e.Op, Tree(e.Y))
 	case sliceExpr:
 		s := "<TODO>"
 		return s
@@ -67,50 +110,15 @@ func (s sliceExpr) ProgString() string {
 	return "<sliceExpr>"
 }
 
-// variableExpr identifies a variable to be looked up and evaluated.
-type variableExpr struct {
-	name string
-}
-
-func (e variableExpr) Eval(context value.Context) value.Value {
-	// TODO
-	return nil
-}
-
-func (e variableExpr) ProgString() string {
-	return e.name
-}
-
-type unary struct {
-	op    string
-	right interface{}
-}
-
-type binary struct {
-	op    string
-	left  interface{}
-	right interface{}
-}
-
-func (b *binary) ProgString() string {
-	// TODO
-	return "<binary>"
-}
-
-func (b *binary) Eval(context value.Context) value.Value {
-	// TODO
-	return nil
-}
-
 // Parser stores the state of the parser.
 type Parser struct {
-	scanner    *scan.Scanner
-	fileName   string
-	lineNum    int
-	errorCount int // Number of errors.
-	peekTok    token.Token
-	curTok     token.Token // most recent token from scanner
-	context    value.Context
+	scanner  *scan.Scanner
+	fileName string
+	lineNum  int
+	errors   scan.ErrorList
+	peekTok  token.Token
+	curTok   token.Token // most recent token from scanner
+	context  value.Context
 }
 
 // NewParser returns a new parser that will read from the scanner.
@@ -159,9 +167,68 @@ func (p *Parser) nextErrorOut(errorOut bool) token.Token {
 	return tok
 }
 
+// bailout is the panic of a parse error, the parser recovers from it at
+// the end of the statement or function.
+type bailout struct{}
+
+// errorf records the error at the most recent token and bails out.
 func (p *Parser) errorf(format string, args ...interface{}) {
-	p.peekTok = token.Token{Type: token.EOF}
-	value.Errorf(format, args...)
+	p.errorAt(p.curTok.Pos, format, args...)
+}
+
+// errorAt records the error at pos and bails out.
+func (p *Parser) errorAt(pos token.Pos, format string, args ...interface{}) {
+	p.errors.Add(pos, fmt.Sprintf(format, args...))
+	panic(bailout{})
+}
+
+// bailedOut reports whether r, recovered from a panic, is a parse error.
+// The errors of values, value.Error, are recorded at the most recent
+// token, other panics continue.
+func (p *Parser) bailedOut(r interface{}) bool {
+	switch r := r.(type) {
+	case nil:
+		return false
+	case bailout:
+		return true
+	case value.Error:
+		p.errors.Add(p.curTok.Pos, r.Error())
+		return true
+	}
+	panic(r)
+}
+
+// skipStmt skips the rest of a statement with an error, up to the
+// newline or semicolon ending it or the end of its block.
+func (p *Parser) skipStmt() {
+	switch p.curTok.Type {
+	case token.Newline, token.Semicolon:
+		return
+	case token.RightBrace:
+		// the error is at the end of the block, it's parsed again
+		if p.peekTok.Type == token.EOF {
+			p.peekTok = p.curTok
+			return
+		}
+	}
+	for {
+		switch p.peek().Type {
+		case token.RightBrace, token.EOF:
+			return
+		case token.Newline, token.Semicolon:
+			p.next()
+			return
+		}
+		p.nextErrorOut(false)
+	}
+}
+
+// skipFunc skips the rest of a function with an error, up to the next
+// function.
+func (p *Parser) skipFunc() {
+	for t := p.peek().Type; t != token.FUNC && t != token.EOF; t = p.peek().Type {
+		p.nextErrorOut(false)
+	}
 }
 
 // Loc returns the current input location in the form "name:line: ".
@@ -182,11 +249,6 @@ func (p *Parser) peek() token.Token {
 	return p.peekTok
 }
 
-func (p *Parser) error(msg string) {
-	// TODO: Improve
-	panic(msg)
-}
-
 func (p *Parser) expectTok(t token.Type) (token.Token, bool) {
 	tok := p.next() // make progress
 	if tok.Type == t {
@@ -211,175 +273,284 @@ func (p *Parser) absorbWhitespace() {
 	}
 }
 
-func (p *Parser) ParseFile() *gst.File {
+// ParseFile parses the file. After an error it continues with the next
+// statement or function, the returned error is the scan.ErrorList of
+// all the errors and the file has the statements and functions parsed
+// without errors.
+func (p *Parser) ParseFile() (*gst.File, error) {
 	p.absorbWhitespace()
 	if p.peek().Type == token.EOF {
 		return &gst.File{
 			PkgName: "",
 			Decls:   []gst.FuncDecl{},
+		}, nil
+	}
+
+	file := &gst.File{}
+	p.parsePackage(file)
+	for p.peek().Type != token.EOF {
+		if decl := p.funcDecl(); decl != nil {
+			file.Decls = append(file.Decls, *decl)
 		}
+		p.absorbWhitespace()
 	}
+	return file, p.errors.Err()
+}
 
-	pos_valid, _ := p.expect(token.Token{token.PACKAGE, 0, "package"})
+// parsePackage parses the package clause of the file.
+func (p *Parser) parsePackage(file *gst.File) {
+	defer func() {
+		if p.bailedOut(recover()) {
+			p.skipFunc()
+		}
+	}()
+	pos_valid, pkg := p.expect(token.Token{Type: token.PACKAGE, Text: "package"})
 	if !pos_valid {
-		p.error("expected package keyword")
-		return nil
+		p.errorf("expected package keyword, got %s", pkg)
 	}
 	ident := p.parseIdent()
 	if ident.Text == "_" {
-		p.error("invalid package name _")
-	}
-	var decls []gst.FuncDecl
-	for p.peek().Type != token.EOF {
-		decls = append(decls, *p.parseFuncDecl())
-		p.absorbWhitespace()
+		p.errorAt(ident.Pos, "invalid package name _")
 	}
+	file.Package = pkg.Pos
+	file.PkgName = ident.Text
+}
 
-	return &gst.File{
-		PkgName: ident.Text,
-		Decls:   decls,
-	}
+// funcDecl parses a function, it returns nil after an error.
+func (p *Parser) funcDecl() (decl *gst.FuncDecl) {
+	defer func() {
+		if p.bailedOut(recover()) {
+			decl = nil
+			p.skipFunc()
+		}
+	}()
+	return p.parseFuncDecl()
 }
 
 func (p *Parser) parseIdent() *token.Token {
-	name := "_"
 	if p.peek().Type == token.Identifier {
-		name = p.peek().Text
-		p.next()
-	} else {
-		p.expectTok(token.Identifier) // use expect() error handling
+		tok := p.next()
+		return &tok
 	}
-	return &token.Token{token.Identifier, 0, name}
+	tok := p.next()
+	p.errorf("expected identifier, got %s", tok)
+	return nil
 }
 
 func (p *Parser) parseFuncDecl() *gst.FuncDecl {
 	p.absorbWhitespace()
-	if func_valid, _ := p.expect(token.Token{token.FUNC, 0, "func"}); !func_valid {
-		p.error(fmt.Sprintf("expected func keyword, got %v", p.peek()))
+	func_valid, funcTok := p.expect(token.Token{Type: token.FUNC, Text: "func"})
+	if !func_valid {
+		p.errorf("expected func keyword, got %s", funcTok)
 	}
 	fnIdent, ok := p.expectTok(token.Identifier)
 	if !ok {
-		p.error(fmt.Sprintf("expected identifier after 'func', got %v", p.peek()))
-	}
-
-	_, ok = p.expectTok(token.LeftParen)
-	if !ok {
-		p.error(fmt.Sprintf("expected '(' after func identifier, got %v", p.peek()))
+		p.errorf("expected identifier after 'func', got %s", fnIdent)
 	}
 
-	_, ok = p.expectTok(token.RightParen)
-	if !ok {
-		p.error(fmt.Sprintf("expected ')' after func identifier, got %v", p.peek()))
+	var decl gst.FuncDecl
+	decl.Func = funcTok.Pos
+	decl.Params = p.parseParams()
+	switch p.peek().Type {
+	case token.Identifier:
+		typ := p.parseIdent()
+		decl.Results = []gst.Field{{Pos: typ.Pos, Type: typ.Text}}
+	case token.LeftParen:
+		decl.Results = p.parseParams()
 	}
 	p.absorbWhitespace()
-	_, ok = p.expectTok(token.LeftBrace)
+	lbrace, ok := p.expectTok(token.LeftBrace)
 	if !ok {
-		p.error(fmt.Sprintf("expected '{' after func identifier, got %v", p.peek()))
+		p.errorf("expected '{' after func signature, got %s", lbrace)
 	}
 
-	p.absorbWhitespace()
-	var decl gst.FuncDecl
-	body, ok := p.parseStmt()
-
-	decl.Body = body
+	decl.Body = p.parseBlockStmt()
+	decl.Body.Lbrace = lbrace.Pos
+	decl.NamePos = fnIdent.Pos
 	decl.Name = fnIdent.Text
-	if !ok {
-		p.error("Error in p.Line()")
-	}
-	p.absorbWhitespace()
-	_, ok = p.expectTok(token.RightBrace)
-	if !ok {
-		p.error(fmt.Sprintf("expected '}' after func body, got %v", p.peek()))
+	if tok, ok := p.expectTok(token.RightBrace); !ok {
+		p.errorf("expected '}' after func body, got %s", tok)
 	}
 	return &decl
 }
 
-func (p *Parser) parseStmt() (s gst.Stmt, ok bool) {
-	t := p.peek()
-	switch t.Type {
-	case token.RETURN:
-		t = p.next()
-		s = &gst.RetStmt{}
-		return s, true
-	default:
-		exprs, ok := p.Line()
-		// no statement found
-		if !ok {
-			p.error("expected statement")
-			return nil, false
+// parseParams parses a parenthesized parameter or result list.
+// As in Go, consecutive names may share a type, "(x, y int64)",
+// and a list of only types declares unnamed fields, "(int64, bool)".
+func (p *Parser) parseParams() []gst.Field {
+	if tok, ok := p.expectTok(token.LeftParen); !ok {
+		p.errorf("expected '(' in func signature, got %s", tok)
+	}
+	var fields []gst.Field
+	typed := false
+	for p.peek().Type != token.RightParen {
+		if len(fields) > 0 {
+			if tok := p.next(); !isComma(tok) {
+				p.errorf("expected ',' or ')' in parameter list, got %s", tok)
+			}
+		}
+		name := p.parseIdent()
+		field := gst.Field{Pos: name.Pos, Name: name.Text}
+		if p.peek().Type == token.Identifier {
+			field.Type = p.parseIdent().Text
+			// earlier names without a type share this one
+			for i := len(fields) - 1; i >= 0 && fields[i].Type == ""; i-- {
+				fields[i].Type = field.Type
+			}
+			typed = true
+		}
+		fields = append(fields, field)
+	}
+	p.next()
+	if !typed {
+		// only types were listed, the fields are unnamed
+		for i := range fields {
+			fields[i].Type = fields[i].Name
+			fields[i].Name = ""
 		}
-		return &gst.ExprStmt{exprs}, true
+	} else if len(fields) > 0 && fields[len(fields)-1].Type == "" {
+		last := fields[len(fields)-1]
+		p.errorAt(last.Pos, "missing type for parameter %v", last.Name)
 	}
+	return fields
+}
 
-	return nil, false
+// isUnary identifies the unary operators.
+var isUnary = map[string]bool{
+	"+": true,
+	"-": true,
+	"^": true,
 }
 
-// Line reads a line of input and returns the values it evaluates.
-// A nil returned slice means there were no values.
-// The boolean reports whether the line is valid.
-func (p *Parser) Line() ([]value.Expr, bool) {
-	tok := p.peek()
-	switch tok.Type {
-	case token.RightParen:
-		// TODO
-		return nil, true
-	case token.Op:
-		// TODO
-		return nil, true
-	}
-	// TODO
+func isComma(tok token.Token) bool {
+	return isOperator(tok, ",")
+}
 
-	exprs, ok := p.expressionList()
-	if !ok {
-		return nil, false
-	}
-	return exprs, true
+func isOperator(tok token.Token, text string) bool {
+	return tok.Type == token.Operator && tok.Text == text
 }
 
-// expressionList:
-//'\n'
-//statementList '\n'
-func (p *Parser) expressionList() ([]value.Expr, bool) {
-	tok := p.next()
-	switch tok.Type {
-	case token.EOF:
-		return nil, false
-	case token.Newline:
-		return nil, true
+// parseBlockStmt parses the statements up to the closing '}' of a block,
+// statements are separated by newlines or semicolons.
+func (p *Parser) parseBlockStmt() *gst.BlockStmt {
+	block := &gst.BlockStmt{}
+	for {
+		p.absorbSeparators()
+		if t := p.peek().Type; t == token.RightBrace || t == token.EOF {
+			return block
+		}
+		if s := p.stmt(); s != nil {
+			block.List = append(block.List, s)
+		}
 	}
-	exprs, ok := p.statementList(tok)
+}
+
+// stmt parses a statement up to its end, it returns nil after an error.
+func (p *Parser) stmt() (s gst.Stmt) {
+	defer func() {
+		if p.bailedOut(recover()) {
+			s = nil
+			p.skipStmt()
+		}
+	}()
+	s, ok := p.parseStmt()
 	if !ok {
-		return nil, false
+		p.errorf("expected statement")
 	}
-	tok = p.next()
-	switch tok.Type {
-	case token.EOF, token.Newline:
+	if p.context.Config().Debug("parse") {
+		p.Println(Tree(s))
+	}
+	switch tok := p.peek(); tok.Type {
+	case token.Newline, token.Semicolon, token.RightBrace:
 	default:
-		p.errorf("unexpected %s", tok)
+		p.errorAt(tok.Pos, "unexpected %s after statement", tok)
 	}
-	if len(exprs) > 0 && p.context.Config().Debug("parse") {
-		p.Println(Tree(exprs))
+	return s
+}
+
+// absorbSeparators skips newlines and semicolons between statements.
+func (p *Parser) absorbSeparators() {
+	for t := p.peek().Type; t == token.Newline || t == token.Semicolon; t = p.peek().Type {
+		p.next()
 	}
-	return exprs, ok
 }
 
-// statementList:
-//expr
-//expr ';' expr
-func (p *Parser) statementList(tok token.Token) ([]value.Expr, bool) {
-	expr := p.expr(tok)
-	var exprs []value.Expr
-	if expr != nil {
-		exprs = []value.Expr{expr}
-	}
-	if p.peek().Type == token.Semicolon {
+func (p *Parser) parseStmt() (s gst.Stmt, ok bool) {
+	t := p.peek()
+	switch t.Type {
+	case token.RETURN:
+		p.next()
+		return &gst.RetStmt{Return: t.Pos}, true
+	case token.GOTO:
 		p.next()
-		more, ok := p.statementList(p.next())
-		if ok {
-			exprs = append(exprs, more...)
+		return &gst.GotoStmt{Goto: t.Pos, Label: p.parseLabel()}, true
+	case token.IF:
+		// if cond goto yes else no
+		// if Kind control goto yes else no
+		p.next()
+		kind := ""
+		tok := p.next()
+		if tok.Type == token.Identifier && p.peek().Type == token.Identifier {
+			kind = tok.Text
+			tok = p.next()
+		}
+		cond := p.expr(tok)
+		if cond == nil {
+			return nil, false
+		}
+		if tok := p.next(); tok.Type != token.GOTO {
+			p.errorf("expected goto after if condition, got %s", tok)
+		}
+		yes := p.parseLabel()
+		if tok := p.next(); tok.Type != token.ELSE {
+			p.errorf("expected else after goto %s, got %s", yes.Name, tok)
+		}
+		no := p.parseLabel()
+		return &gst.IfStmt{If: t.Pos, Kind: kind, Cond: cond, Yes: yes, No: no}, true
+	case token.Identifier:
+		ident := p.next()
+		if p.peek().Type == token.Colon {
+			p.next()
+			// the label applies to the next statement
+			p.absorbSeparators()
+			if t := p.peek().Type; t == token.RightBrace || t == token.EOF {
+				p.errorf("missing statement after label %s", ident.Text)
+			}
+			s, ok := p.parseStmt()
+			if !ok {
+				return nil, false
+			}
+			return &gst.LabeledStmt{Label: p.variable(ident), Stmt: s}, true
+		}
+		if p.peek().Type == token.Assign {
+			assign := p.next()
+			rhs := p.opOrExpr(p.next())
+			if rhs == nil {
+				return nil, false
+			}
+			return &gst.AssignStmt{Lhs: p.variable(ident), Assign: assign.Pos, Rhs: rhs}, true
 		}
+		expr := p.expr(ident)
+		if expr == nil {
+			return nil, false
+		}
+		return &gst.ExprStmt{Start: t.Pos, Exprs: []value.Expr{expr}}, true
+	default:
+		expr := p.expr(p.next())
+		if expr == nil {
+			return nil, false
+		}
+		return &gst.ExprStmt{Start: t.Pos, Exprs: []value.Expr{expr}}, true
 	}
-	return exprs, true
+}
+
+// parseLabel parses the label of a goto or if.
+func (p *Parser) parseLabel() *gst.Ident {
+	tok, ok := p.expectTok(token.Identifier)
+	if !ok {
+		p.errorf("expected label, got %s", tok)
+	}
+	return p.variable(tok)
 }
 
 // expr
@@ -389,24 +560,142 @@ func (p *Parser) expr(tok token.Token) value.Expr {
 	if p.peek().Type == token.Assign && tok.Type != token.Identifier {
 		p.errorf("cannot assign to %s", tok)
 	}
-	expr := p.operand(tok, true)
-	tok = p.peek()
-	switch tok.Type {
-	case token.Newline, token.EOF, token.RightParen, token.RightBrack, token.Semicolon:
+	return p.exprEnd(p.binaryExpr(tok, 1))
+}
+
+// exprEnd checks the token following the expression, expr.
+func (p *Parser) exprEnd(expr value.Expr) value.Expr {
+	switch tok := p.peek(); tok.Type {
+	case token.Newline, token.EOF, token.RightParen, token.RightBrack, token.RightBrace, token.Semicolon, token.GOTO:
 		return expr
 	case token.Identifier:
 		// TODO
 		return nil
-	case token.Operator, token.Assign:
+	}
+	tok := p.peek()
+	p.errorAt(tok.Pos, "after expression: unexpected %s", tok)
+	return nil
+}
+
+// binaryExpr parses operands joined by binary operators with precedence
+// at least prec1. As in Go, operators of the same precedence associate
+// to the left, x - y - z is (x - y) - z.
+func (p *Parser) binaryExpr(tok token.Token, prec1 int) value.Expr {
+	return p.binaryOps(p.operand(tok, true), prec1)
+}
+
+// binaryOps parses the binary operators and operands following the
+// operand, expr, with precedence at least prec1.
+func (p *Parser) binaryOps(expr value.Expr, prec1 int) value.Expr {
+	for {
+		op := p.peek()
+		prec := precedence(op)
+		if prec < prec1 {
+			return expr
+		}
 		p.next()
-		return &binary{
-			left:  expr,
-			op:    tok.Text,
-			right: p.expr(p.next()),
+		expr = &gst.BinaryExpr{
+			OpPos: op.Pos,
+			X:     expr,
+			Op:    op.Text,
+			Y:     p.binaryExpr(p.next(), prec+1),
 		}
 	}
-	p.errorf("after expression: unexpected %s", p.peek())
-	return nil
+}
+
+// opOrExpr parses the right side of an assignment, either an SSA op,
+// "Op <type> [auxint] {aux} args...", or an expression. The aux is a
+// variable or a quoted string. "x <y> z" isn't a valid comparison, so
+// a type in angle brackets marks an op.
+func (p *Parser) opOrExpr(tok token.Token) value.Expr {
+	if tok.Type != token.Identifier || !isOperator(p.peek(), "<") {
+		return p.expr(tok)
+	}
+	lt := p.next()
+	// pointer types, "<*int>", aren't comparisons, there's no "*x"
+	ptr := ""
+	for t := p.peek(); isOperator(t, "*") || isOperator(t, "**"); t = p.peek() {
+		ptr += p.next().Text
+	}
+	typ := p.next()
+	if ptr == "" && (typ.Type != token.Identifier || !isOperator(p.peek(), ">")) {
+		// a comparison, tok < typ ...
+		x := &gst.BinaryExpr{
+			OpPos: lt.Pos,
+			X:     p.variable(tok),
+			Op:    lt.Text,
+			Y:     p.binaryExpr(typ, precedence(lt)+1),
+		}
+		return p.exprEnd(p.binaryOps(x, 1))
+	}
+	if typ.Type != token.Identifier {
+		p.errorf("expected type of %s, got %s", tok.Text, typ)
+	}
+	if tok := p.next(); !isOperator(tok, ">") {
+		p.errorf("expected '>' after type, got %s", tok)
+	}
+	op := &gst.OpExpr{OpPos: tok.Pos, Op: tok.Text, Type: ptr + typ.Text}
+	if p.peek().Type == token.LeftBrack {
+		p.next()
+		op.AuxInt = p.auxInt()
+		if tok := p.next(); tok.Type != token.RightBrack {
+			p.errorf("expected ']' after auxint, got %s", tok)
+		}
+	}
+	if p.peek().Type == token.LeftBrace {
+		p.next()
+		if p.peek().Type == token.String {
+			op.AuxString = p.next().Text
+		} else {
+			op.Aux = p.variable(*p.parseIdent())
+		}
+		if tok := p.next(); tok.Type != token.RightBrace {
+			p.errorf("expected '}' after aux, got %s", tok)
+		}
+	}
+	for p.peek().Type == token.Identifier {
+		op.Args = append(op.Args, p.variable(p.next()))
+	}
+	return op
+}
+
+// auxInt parses the integer of an op's auxint, "[8]" or "[-8]".
+func (p *Parser) auxInt() int64 {
+	tok := p.next()
+	text := ""
+	if isOperator(tok, "-") {
+		text = "-"
+		tok = p.next()
+	}
+	if tok.Type != token.Number {
+		p.errorf("expected auxint, got %s", tok)
+	}
+	n, err := strconv.ParseInt(text+tok.Text, 0, 64)
+	if err != nil {
+		p.errorf("auxint %s: %s", text+tok.Text, err)
+	}
+	return n
+}
+
+// precedence returns the precedence of the binary operator tok, as in Go,
+// or 0 if tok isn't a binary operator.
+func precedence(tok token.Token) int {
+	if tok.Type != token.Operator {
+		return 0
+	}
+	switch tok.Text {
+	case "||":
+		return 1
+	case "&&":
+		return 2
+	case "==", "!=", "<", "<=", ">", ">=":
+		return 3
+	case "+", "-", "|", "^":
+		return 4
+	case "*", "/", "%", "<<", ">>", "&", "&^":
+		return 5
+	}
+	return 0
 }
 
 // operand
@@ -415,15 +704,29 @@ func (p *Parser) expr(tok token.Token) value.Expr {
 //string constant
 //vector
 //operand [ Expr ]...
-//unop Expr
+//unop operand
+//call
 func (p *Parser) operand(tok token.Token, indexOK bool) value.Expr {
 	var expr value.Expr
 	switch tok.Type {
 	case token.Identifier:
-		// TODO
+		if p.peek().Type == token.LeftParen {
+			expr = p.call(tok)
+			break
+		}
 		fallthrough
 	case token.Number, token.Rational, token.String, token.LeftParen:
 		expr = p.numberOrVector(tok)
+	case token.Operator:
+		if !isUnary[tok.Text] {
+			p.errorf("unexpected %s", tok)
+		}
+		expr = &gst.UnaryExpr{
+			OpPos: tok.Pos,
+			Op:    tok.Text,
+			X:     p.operand(p.next(), indexOK),
+		}
+		return expr
 	default:
 		p.errorf("unexpected %s", tok)
 	}
@@ -433,22 +736,41 @@ func (p *Parser) operand(tok token.Token, indexOK bool) value.Expr {
 	return expr
 }
 
+// call
+//identifier ( )
+//identifier ( expr , expr ... )
+func (p *Parser) call(fun token.Token) value.Expr {
+	p.next()
+	call := &gst.CallExpr{Fun: p.variable(fun)}
+	for p.peek().Type != token.RightParen {
+		if len(call.Args) > 0 {
+			if tok := p.next(); !isComma(tok) {
+				p.errorf("expected ',' or ')' in arguments to %s, got %s", fun.Text, tok)
+			}
+		}
+		call.Args = append(call.Args, p.binaryExpr(p.next(), 1))
+	}
+	p.next()
+	return call
+}
+
 // index
 //expr
 //expr [ expr ]
 //expr [ expr ] [ expr ] ....
 func (p *Parser) index(expr value.Expr) value.Expr {
 	for p.peek().Type == token.LeftBrack {
-		p.next()
+		lbrack := p.next()
 		index := p.expr(p.next())
 		tok := p.next()
 		if tok.Type != token.RightBrack {
 			p.errorf("expected right bracket, found %s", tok)
 		}
-		expr = &binary{
-			op:    "[]",
-			left:  expr,
-			right: index,
+		expr = &gst.BinaryExpr{
+			OpPos: lbrack.Pos,
+			Op:    "[]",
+			X:     expr,
+			Y:     index,
 		}
 	}
 	return expr
@@ -466,7 +788,7 @@ func (p *Parser) number(tok token.Token) (expr value.Expr, str string) {
 	text := tok.Text
 	switch tok.Type {
 	case token.Identifier:
-		expr = p.variable(text)
+		expr = p.variable(tok)
 	case token.String:
 		// TODO
 		str = "<token.String>"
@@ -511,9 +833,10 @@ func (p *Parser) numberOrVector(tok token.Token) value.Expr {
 	return slice
 }
 
-func (p *Parser) variable(name string) variableExpr {
-	return variableExpr{
-		name: name,
+func (p *Parser) variable(tok token.Token) *gst.Ident {
+	return &gst.Ident{
+		NamePos: tok.Pos,
+		Name:    tok.Text,
 	}
 }
 
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bjwbell/gir/token"
+)
+
+// Error is an error at a position of the source.
+type Error struct {
+	Pos token.Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList is a list of errors in the order they're found.
+type ErrorList []*Error
+
+// Add adds the error, msg, at pos to the list.
+func (p *ErrorList) Add(pos token.Pos, msg string) {
+	*p = append(*p, &Error{Pos: pos, Msg: msg})
+}
+
+// Error returns the first error and the number of the others.
+func (p ErrorList) Error() string {
+	switch len(p) {
+	case 0:
+		return "no errors"
+	case 1:
+		return p[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", p[0], len(p)-1)
+}
+
+// Err returns the list as an error, or nil if it's empty.
+func (p ErrorList) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
+
+// PrintError prints the error, err, to w, each error of an ErrorList
+// on its own line.
+func PrintError(w io.Writer, err error) {
+	if list, ok := err.(ErrorList); ok {
+		for _, e := range list {
+			fmt.Fprintf(w, "%s\n", e)
+		}
+	} else if err != nil {
+		fmt.Fprintf(w, "%s\n", err)
+	}
+}
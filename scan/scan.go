@@ -29,7 +29,8 @@ type Scanner struct {
 	leftDelim  string  // start of action
 	rightDelim string  // end of action
 	state      stateFn // the next lexing function to enter
-	line       int     // line number in input
+	line       int     // line number of the start of this item
+	col        int     // column of the start of this item
 	pos        int     // current position in the input
 	start      int     // start position of this item
 	width      int     // width of last rune read from input
@@ -37,16 +38,34 @@ type Scanner struct {
 
 // errorf returns an error token and continues to scan.
 func (l *Scanner) errorf(format string, args ...interface{}) stateFn {
-	l.tokens <- token.Token{token.Error, l.start, fmt.Sprintf(format, args...)}
+	l.tokens <- token.Token{token.Error, l.position(), fmt.Sprintf(format, args...)}
 	return lexAny
 }
 
+// position returns the position of the start of this item.
+func (l *Scanner) position() token.Pos {
+	return token.Pos{File: l.name, Line: l.line, Col: l.col}
+}
+
+// advance moves the position of the start of this item past the text.
+func (l *Scanner) advance(text string) {
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+	}
+}
+
 // New creates a new scanner for the input string.
 func New(context value.Context, name string, r io.ByteReader) *Scanner {
 	l := &Scanner{
 		r:       r,
 		name:    name,
 		line:    1,
+		col:     1,
 		tokens:  make(chan token.Token, 2), // We need a little room to save tokens.
 		context: context,
 		state:   lexAny,
@@ -106,21 +125,20 @@ func (l *Scanner) backup() {
 
 //  passes an item back to the client.
 func (l *Scanner) emit(t token.Type) {
-	if t == token.Newline {
-		l.line++
-	}
 	s := l.input[l.start:l.pos]
+	tok := token.Token{t, l.position(), s}
 	config := l.context.Config()
 	if config.Debug("tokens") {
-		fmt.Fprintf(config.Output(), "%s:%d: emit %s\n", l.name, l.line, token.Token{t, l.line, s})
+		fmt.Fprintf(config.Output(), "%s: emit %s\n", tok.Pos, tok)
 	}
-	l.tokens <- token.Token{t, l.line, s}
-	l.start = l.pos
+	l.tokens <- tok
+	l.ignore()
 	l.width = 0
 }
 
 // ignore skips over the pending input before this point.
 func (l *Scanner) ignore() {
+	l.advance(l.input[l.start:l.pos])
 	l.start = l.pos
 }
 
@@ -149,8 +167,9 @@ func lexComment(l *Scanner) stateFn {
 		}
 	}
 	if len(l.input) > 0 {
-		l.pos = len(l.input)
-		l.start = l.pos - 1
+		l.pos = len(l.input) - 1
+		l.ignore()
+		l.pos++
 		// Emitting newline also advances l.line.
 		l.emit(token.Newline) // TODO: pass comments up?
 	}
@@ -178,6 +197,9 @@ func lexAny(l *Scanner) stateFn {
 	case r == ';':
 		l.emit(token.Semicolon)
 		return lexAny
+	case r == ':':
+		l.emit(token.Colon)
+		return lexAny
 	case r == '#':
 		return lexComment
 	case isSpace(r):
@@ -188,9 +210,6 @@ func lexAny(l *Scanner) stateFn {
 		return lexRawQuote
 	case r == '\'':
 		return lexChar
-	case r == '-':
-		// TODO
-		fallthrough
 	case r == '.' || '0' <= r && r <= '9':
 		l.backup()
 		return lexNumber
@@ -202,16 +221,19 @@ func lexAny(l *Scanner) stateFn {
 		l.next()
 		fallthrough // for ==
 	case l.isOperator(r):
-		// Must be after after = so == is an operator,
-		// and after numbers, so '-' can be a sign.
+		// Must be after after = so == is an operator.
+		// As in Go, '-' is always an operator, a leading
+		// minus is parsed as a unary expression.
 		return lexOperator
 	case isAlphaNumeric(r):
 		l.backup()
 		return lexIdentifier
 	case r == '[':
-		panic("unimplemented")
+		l.emit(token.LeftBrack)
+		return lexAny
 	case r == ']':
-		panic("unimplemented")
+		l.emit(token.RightBrack)
+		return lexAny
 	case r == '{':
 		l.emit(token.LeftBrace)
 		return lexAny
@@ -255,6 +277,12 @@ Loop:
 			l.emit(token.PACKAGE)
 		} else if l.IsReturn(lit) {
 			l.emit(token.RETURN)
+		} else if l.IsGoto(lit) {
+			l.emit(token.GOTO)
+		} else if l.IsIf(lit) {
+			l.emit(token.IF)
+		} else if l.IsElse(lit) {
+			l.emit(token.ELSE)
 		} else {
 			return l.errorf("unrecognized keyword %v", lit)
 		}
@@ -270,7 +298,13 @@ func (l *Scanner) IsKeyword(lit string) bool {
 		return true
 	} else if lit == "package" {
 		return true
-	} else if lit == "ret" {
+	} else if lit == "return" {
+		return true
+	} else if lit == "goto" {
+		return true
+	} else if lit == "if" {
+		return true
+	} else if lit == "else" {
 		return true
 	}
 
@@ -298,6 +332,27 @@ func (l *Scanner) IsReturn(lit string) bool {
 	return false
 }
 
+func (l *Scanner) IsGoto(lit string) bool {
+	if lit == "goto" {
+		return true
+	}
+	return false
+}
+
+func (l *Scanner) IsIf(lit string) bool {
+	if lit == "if" {
+		return true
+	}
+	return false
+}
+
+func (l *Scanner) IsElse(lit string) bool {
+	if lit == "else" {
+		return true
+	}
+	return false
+}
+
 // IsBinary identifies the binary operators; these can be used in reductions.
 var IsBinary = map[string]bool{
 	"!=": true,
@@ -400,7 +455,7 @@ func (l *Scanner) Next() token.Token {
 		close(l.tokens)
 		l.tokens = nil
 	}
-	return token.Token{token.EOF, l.pos, "EOF"}
+	return token.Token{token.EOF, l.position(), "EOF"}
 }
 
 // lexNumber scans a number: decimal, octal, hex, float, or imaginary. This
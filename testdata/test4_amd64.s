@@ -1,2 +1,6 @@
+// +build amd64 !noasm !appengine
+
+#include "textflag.h"
+
 TEXT ·T4(SB),$0-0
 RET
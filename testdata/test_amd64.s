@@ -1,2 +1,9 @@
+// +build amd64 !noasm !appengine
+
+#include "textflag.h"
+
 TEXT ·test1(SB),$0-0
 RET
+
+TEXT ·test2(SB),$0-0
+RET
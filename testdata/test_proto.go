@@ -2,3 +2,4 @@
 
 package testdata
 func test1()
+func test2()
@@ -8,9 +8,39 @@ import (
 
 type Type int
 
+// Pos is a position in a source file. Line and Col, the byte offset in
+// the line, count from 1, a Pos with Line 0 is unknown.
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+// IsValid reports whether the position is known.
+func (p Pos) IsValid() bool {
+	return p.Line > 0
+}
+
+// String returns the position as "file:line:col", the parts that are
+// known.
+func (p Pos) String() string {
+	s := p.File
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Col)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// Token is a token of the source and the position of its first byte.
 type Token struct {
 	Type Type
-	Line int
+	Pos
 	Text string
 }
 
@@ -33,6 +63,7 @@ const (
 	LeftBrace  // '{'
 	RightBrace // '}'
 	Semicolon  // ';'
+	Colon      // ':'
 	LeftBrack  // '['
 	RightBrack // ']'
 	String     // quoted string (includes quotes)
@@ -41,6 +72,9 @@ const (
 	FUNC    // 'func'
 	PACKAGE // 'package'
 	RETURN  // 'return'
+	GOTO    // 'goto'
+	IF      // 'if'
+	ELSE    // 'else'
 )
 
 func (i Token) String() string {
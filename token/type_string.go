@@ -4,9 +4,9 @@ package token
 
 import "fmt"
 
-const _Type_name = "EOFErrorNewlineAssignCharIdentifierNumberOperatorOpRationalLeftParenRightParenLeftBraceRightBraceSemicolonLeftBrackRightBrackStringFUNCPACKAGERET"
+const _Type_name = "EOFErrorNewlineAssignCharIdentifierNumberOperatorOpRationalLeftParenRightParenLeftBraceRightBraceSemicolonColonLeftBrackRightBrackStringFUNCPACKAGERETURNGOTOIFELSE"
 
-var _Type_index = [...]uint8{0, 3, 8, 15, 21, 25, 35, 41, 49, 51, 59, 68, 78, 87, 97, 106, 115, 125, 131, 135, 142, 145}
+var _Type_index = [...]uint8{0, 3, 8, 15, 21, 25, 35, 41, 49, 51, 59, 68, 78, 87, 97, 106, 111, 120, 130, 136, 140, 147, 153, 157, 159, 163}
 
 func (i Type) String() string {
 	if i < 0 || i >= Type(len(_Type_index)-1) {
@@ -0,0 +1,42 @@
+package value
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bjwbell/gir/config"
+)
+
+// Float is a floating-point literal, like 1.5 or 2e-3. GIR values are
+// at most 64 bits, the literal is the nearest float64.
+type Float float64
+
+func setFloatString(s string) (Float, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	return Float(f), err
+}
+
+func (f Float) String() string {
+	return "(" + f.Sprint(debugConf) + ")"
+}
+
+func (f Float) Sprint(conf *config.Config) string {
+	if verb, prec, ok := conf.FloatFormat(); ok {
+		return strconv.FormatFloat(float64(f), verb, prec, 64)
+	}
+	return f.ProgString()
+}
+
+// ProgString returns the shortest literal of f that's a float in Go too,
+// 2.0 and not 2.
+func (f Float) ProgString() string {
+	s := strconv.FormatFloat(float64(f), 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+func (f Float) Eval(Context) Value {
+	return f
+}
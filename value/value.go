@@ -48,6 +48,8 @@ func Parse(conf *config.Config, s string) (Value, error) {
 	if err == nil {
 		return i, nil
 	}
-	// TODO
+	if strings.ContainsAny(s, ".eE") && !strings.ContainsAny(s, "xX") {
+		return setFloatString(s)
+	}
 	return nil, err
 }